@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"wp-go-static/internal/batch"
+	"wp-go-static/internal/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// BuildCmd ...
+var BuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Scrape the Wordpress website and create its sitemap, robots and redirects",
+	RunE:  buildCmdF,
+}
+
+const (
+	bindFlagBuildPrefix = "build"
+	// bindFlagSitePrefix is the section of the settings shared by every command
+	bindFlagSitePrefix = "site"
+)
+
+// stage is a step of the build and its outcome
+type stage struct {
+	name     string
+	run      func() (string, error)
+	enabled  bool
+	status   string
+	detail   string
+	duration time.Duration
+}
+
+func init() {
+	// Define command-line flags, shared by every stage
+	BuildCmd.PersistentFlags().String("dir", "dump", "directory to save downloaded files")
+	BuildCmd.PersistentFlags().String("url", "", "URL to scrape")
+	BuildCmd.PersistentFlags().String("replace-url", "", "Replace with a specific url")
+	BuildCmd.PersistentFlags().StringToString("headers", map[string]string{}, "Additional headers")
+	BuildCmd.PersistentFlags().Bool("sitemap", true, "Create the sitemap from the scraped pages")
+	BuildCmd.PersistentFlags().Bool("robots", true, "Create the robots.txt")
+	BuildCmd.PersistentFlags().String("redirects", "", "Export the redirects of the site as netlify, nginx, apache or html")
+	BuildCmd.PersistentFlags().String("state-dir", "", "Directory of the state kept between builds, defaults to --dir with -state appended, outside of the deployed files")
+	BuildCmd.PersistentFlags().Int("sites-concurrency", 2, "Maximum number of sites of the sites list built at once")
+	BuildCmd.PersistentFlags().String("sites-report", "", "Write the combined report of the sites list to this JSON file")
+
+	// Bind command-line flags to Viper, the site settings go to the site section
+	BuildCmd.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		prefix := bindFlagBuildPrefix
+		switch flag.Name {
+		case "dir", "url", "replace-url", "headers":
+			prefix = bindFlagSitePrefix
+		}
+		bindFlag := fmt.Sprintf("%s.%s", prefix, flag.Name)
+		viper.BindPFlag(bindFlag, BuildCmd.PersistentFlags().Lookup(flag.Name))
+	})
+
+	RootCmd.AddCommand(BuildCmd)
+}
+
+func buildCmdF(command *cobra.Command, args []string) error {
+	config := config.Config{}
+	viper.Unmarshal(&config)
+
+	if len(config.Sites) > 0 {
+		return buildSites(config)
+	}
+
+	config.ApplySite(viper.IsSet)
+	if err := prepareBuild(&config); err != nil {
+		return err
+	}
+
+	_, stages, failed := buildSite(config, log.Default())
+	printBuildSummary(stages)
+
+	if failed != nil {
+		return fmt.Errorf("build failed at the %s stage", failed.name)
+	}
+	return nil
+}
+
+// prepareBuild points the stages at what build produces and checks every
+// stage up front, not after a long crawl
+func prepareBuild(config *config.Config) error {
+	if config.Build.Redirects != "" {
+		config.Scrape.Redirects = config.Build.Redirects
+	}
+	config.Sitemap.Source = sitemapSourceScrape
+	if config.Build.StateDir != "" {
+		config.Scrape.StateDir = config.Build.StateDir
+	}
+	config.Sitemap.StateDir = config.Scrape.StateDir
+
+	if err := validateScrape(config.Scrape); err != nil {
+		return err
+	}
+	if config.Build.Sitemap {
+		if err := validateSitemap(config.Sitemap); err != nil {
+			return err
+		}
+	}
+	if config.Build.Robots {
+		if err := validateRobots(config.Robots); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// buildSite runs the stages of the build of a site, prepared by prepareBuild,
+// and returns its scrape, the stages and the stage that failed. Every stage logs to logger
+func buildSite(config config.Config, logger *log.Logger) (*Scrape, []*stage, *stage) {
+	scrape := NewScrape()
+	scrape.config = config
+	scrape.logger = logger
+
+	stages := []*stage{
+		{
+			name:    "scrape",
+			enabled: true,
+			run: func() (string, error) {
+				if err := runScrape(scrape); err != nil {
+					return "", err
+				}
+				if err := scrape.saveReport(); err != nil {
+					return "", err
+				}
+
+				summary := scrape.report.Summary()
+				detail := fmt.Sprintf("%d URLs, %d broken", summary.Checked, summary.Broken)
+				return detail, scrape.report.Check(config.Scrape.Fail5xx, config.Scrape.MaxBroken)
+			},
+		},
+		{
+			name:    "sitemap",
+			enabled: config.Build.Sitemap,
+			run: func() (string, error) {
+				return config.Sitemap.File, scrapedSitemap(config.Sitemap, logger)
+			},
+		},
+		{
+			name:    "robots",
+			enabled: config.Build.Robots,
+			run: func() (string, error) {
+				_, err := saveRobots(config.Robots, logger)
+				return config.Robots.File, err
+			},
+		},
+		{
+			name:    "redirects",
+			enabled: config.Scrape.Redirects != "",
+			run: func() (string, error) {
+				return config.Scrape.Redirects, scrape.exportRedirects()
+			},
+		},
+		{
+			name:    "prune",
+			enabled: config.Scrape.Prune || config.Scrape.PruneDryRun,
+			run: func() (string, error) {
+				return "", scrape.prune()
+			},
+		},
+	}
+
+	failed := runStages(stages)
+	return scrape, stages, failed
+}
+
+// runStages runs the enabled stages in order, skipping the ones after a
+// failure, and returns the stage that failed
+func runStages(stages []*stage) *stage {
+	var failed *stage
+	for _, s := range stages {
+		if !s.enabled || failed != nil {
+			s.status = batch.StatusSkipped
+			continue
+		}
+
+		start := time.Now()
+		detail, err := s.run()
+		s.duration = time.Since(start)
+		s.detail = detail
+
+		if err != nil {
+			s.status = batch.StatusFailed
+			s.detail = err.Error()
+			failed = s
+			continue
+		}
+		s.status = batch.StatusOK
+	}
+	return failed
+}
+
+// printBuildSummary shows the outcome of every stage
+func printBuildSummary(stages []*stage) {
+	fmt.Println("Build summary:")
+	for _, s := range stages {
+		if s.status == batch.StatusSkipped {
+			fmt.Printf("  %-10s %s\n", s.name, s.status)
+			continue
+		}
+		fmt.Printf("  %-10s %-8s %8s  %s\n", s.name, s.status, s.duration.Round(time.Millisecond), s.detail)
+	}
+}
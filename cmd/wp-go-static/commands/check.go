@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"wp-go-static/internal/check"
+	"wp-go-static/internal/config"
+	"wp-go-static/internal/redirects"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// CheckCmd ...
+var CheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the links of the exported website",
+	RunE:  checkCmdF,
+}
+
+const (
+	bindFlagCheckPrefix = "check"
+)
+
+func init() {
+	// Define command-line flags
+	CheckCmd.PersistentFlags().String("dir", "dump", "directory with the exported files")
+	CheckCmd.PersistentFlags().String("url", "", "URL of the original Wordpress website")
+	CheckCmd.PersistentFlags().String("replace-url", "", "URL the export is published at")
+	CheckCmd.PersistentFlags().String("redirects", "", "format of the exported redirects, netlify, nginx or apache, found by their default file in --dir when empty")
+	CheckCmd.PersistentFlags().String("redirects-file", "", "file the redirects were exported to, defaults to _redirects, redirects.conf or .htaccess in --dir")
+
+	// Bind command-line flags to Viper
+	CheckCmd.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		bindFlag := fmt.Sprintf("%s.%s", bindFlagCheckPrefix, flag.Name)
+		viper.BindPFlag(bindFlag, CheckCmd.PersistentFlags().Lookup(flag.Name))
+	})
+
+	RootCmd.AddCommand(CheckCmd)
+}
+
+func checkCmdF(command *cobra.Command, args []string) error {
+	config := config.Config{}
+	viper.Unmarshal(&config)
+	config.ApplySite(viper.IsSet)
+
+	if err := validateExistingDir("check.dir", config.Check.Dir); err != nil {
+		return err
+	}
+
+	rules, err := checkRedirects(config.Check)
+	if err != nil {
+		return err
+	}
+
+	checker, err := check.New(config.Check.Dir, config.Check.URL, config.Check.ReplaceURL, rules)
+	if err != nil {
+		return err
+	}
+
+	issues, err := checker.Run()
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		log.Printf("%s: %s (%s)\n", issue.File, issue.Ref, issue.Reason)
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("found %d broken references", len(issues))
+	}
+
+	log.Println("No broken references found")
+	return nil
+}
+
+// checkRedirects loads the exported redirects. Without a format, the default
+// file of every format is looked for in the output directory
+func checkRedirects(cfg config.CheckConfig) ([]redirects.Rule, error) {
+	formats := []string{cfg.Redirects}
+	if cfg.Redirects == "" {
+		formats = []string{redirects.FormatNetlify, redirects.FormatNginx, redirects.FormatApache}
+	}
+
+	var rules []redirects.Rule
+	for _, format := range formats {
+		path := cfg.RedirectsFile
+		if path == "" {
+			path = filepath.Join(cfg.Dir, redirects.DefaultFile(format))
+		}
+
+		formatRules, err := redirects.LoadFormat(path, format)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, formatRules...)
+	}
+
+	return rules, nil
+}
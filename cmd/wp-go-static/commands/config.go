@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"wp-go-static/internal/config"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigCmd ...
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+}
+
+// ConfigPrintCmd ...
+var ConfigPrintCmd = &cobra.Command{
+	Use:   "print [section...]",
+	Short: "Print the effective configuration, merged from the defaults, the config file, the environment and the flags",
+	RunE:  configPrintCmdF,
+}
+
+// redacted replaces the secrets in the printed configuration
+const redacted = "********"
+
+// secretKeys are the settings that are not printed as is
+var secretKeys = []string{"password", "authorization", "cookie"}
+
+func init() {
+	// The format is an option of the command, not a setting, so it is not bound to Viper
+	ConfigPrintCmd.Flags().String("format", "yaml", "Output format: yaml, toml or json")
+
+	ConfigCmd.AddCommand(ConfigPrintCmd)
+	RootCmd.AddCommand(ConfigCmd)
+}
+
+// loadConfig merges the configuration file given with --config into
+// the settings, after checking it
+func loadConfig(command *cobra.Command, args []string) error {
+	if cfgFile == "" {
+		return nil
+	}
+
+	if err := config.CheckFile(cfgFile); err != nil {
+		return err
+	}
+
+	viper.SetConfigFile(cfgFile)
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("error reading config file %s: %v", cfgFile, err)
+	}
+
+	return nil
+}
+
+func configPrintCmdF(command *cobra.Command, args []string) error {
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+
+	settings, err := effectiveSettings()
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		selected := make(map[string]interface{})
+		for _, section := range args {
+			value, ok := settings[strings.ToLower(section)]
+			if !ok {
+				return fmt.Errorf("unknown config section: %s", section)
+			}
+			selected[strings.ToLower(section)] = value
+		}
+		settings = selected
+	}
+	redact(settings)
+
+	var buf bytes.Buffer
+	switch format {
+	case "yaml":
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		err = enc.Encode(settings)
+	case "toml":
+		err = toml.NewEncoder(&buf).Encode(settings)
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(settings)
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+	if err != nil {
+		return fmt.Errorf("error encoding config: %v", err)
+	}
+
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// effectiveSettings returns the settings the commands run with, the site
+// section applied to them, keyed like the configuration file
+func effectiveSettings() (map[string]interface{}, error) {
+	cfg := config.Config{}
+	if err := viper.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("error reading config: %v", err)
+	}
+	cfg.ApplySite(viper.IsSet)
+
+	settings := make(map[string]interface{})
+	if err := mapstructure.Decode(cfg, &settings); err != nil {
+		return nil, fmt.Errorf("error reading config: %v", err)
+	}
+	return normalize(settings).(map[string]interface{}), nil
+}
+
+// normalize turns what mapstructure leaves as is into values every format
+// prints the way they are written: durations and the lists of sections
+func normalize(value interface{}) interface{} {
+	switch value := value.(type) {
+	case time.Duration:
+		return value.String()
+	case map[string]interface{}:
+		for key, v := range value {
+			value[key] = normalize(v)
+		}
+		return value
+	}
+
+	list := reflect.ValueOf(value)
+	if list.Kind() != reflect.Slice || list.Type().Elem().Kind() != reflect.Struct {
+		return value
+	}
+	sections := make([]interface{}, list.Len())
+	for i := range sections {
+		section := make(map[string]interface{})
+		if err := mapstructure.Decode(list.Index(i).Interface(), &section); err != nil {
+			return value
+		}
+		sections[i] = normalize(section)
+	}
+	return sections
+}
+
+// redact hides the values of the secret settings and headers
+func redact(settings map[string]interface{}) {
+	for key, value := range settings {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			redact(value)
+		case []interface{}:
+			for _, item := range value {
+				if item, ok := item.(map[string]interface{}); ok {
+					redact(item)
+				}
+			}
+		case map[string]string:
+			for name := range value {
+				if isSecret(name) && value[name] != "" {
+					value[name] = redacted
+				}
+			}
+		case string:
+			if isSecret(key) && value != "" {
+				settings[key] = redacted
+			}
+		}
+	}
+}
+
+// isSecret reports whether the setting or header holds a secret
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
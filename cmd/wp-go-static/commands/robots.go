@@ -2,7 +2,7 @@ package commands
 
 import (
 	"fmt"
-	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"wp-go-static/internal/config"
+	"wp-go-static/pkg/robots"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -25,14 +26,31 @@ var RobotsCmd = &cobra.Command{
 
 const (
 	bindFlagRobotsPrefix = "robots"
+
+	// robotsModeMerge adds the template rules to the ones of the live robots.txt
+	robotsModeMerge = "merge"
+	// robotsModeOverride replaces the live groups of the user agents of the template
+	robotsModeOverride = "override"
 )
 
+// defaultSitemapFiles are the files written by the sitemap command, in the
+// order they are listed when --sitemap is not set
+var defaultSitemapFiles = []string{"sitemap_index.xml", "sitemap.xml"}
+
 func init() {
 	// Define command-line flags
 	RobotsCmd.PersistentFlags().String("dir", "dump", "directory to save downloaded files")
 	RobotsCmd.PersistentFlags().String("url", "", "URL to scrape")
 	RobotsCmd.PersistentFlags().String("replace-url", "", "Replace with a specific url")
 	RobotsCmd.PersistentFlags().String("file", "robots.txt", "Output robots file name")
+	RobotsCmd.PersistentFlags().String("template", "", "robots.txt file whose groups are applied to the live one, or used alone without --url")
+	RobotsCmd.PersistentFlags().String("mode", robotsModeMerge, "How the template and the --allow/--disallow rules are applied: merge adds them to the live groups, override replaces the live groups of the same user agents")
+	RobotsCmd.PersistentFlags().StringSlice("allow", nil, "Allow rules to add for --user-agent")
+	RobotsCmd.PersistentFlags().StringSlice("disallow", nil, "Disallow rules to add for --user-agent")
+	RobotsCmd.PersistentFlags().String("user-agent", "*", "User agent of the --allow and --disallow rules")
+	RobotsCmd.PersistentFlags().StringSlice("sitemap", nil, "Sitemap files to list, relative to --replace-url. Defaults to the sitemap_index.xml and sitemap.xml found in --dir")
+	RobotsCmd.PersistentFlags().Bool("keep-sitemaps", false, "Keep the Sitemap lines of the live robots.txt along with the generated sitemaps")
+	RobotsCmd.PersistentFlags().Bool("allow-disallow-all", false, "Write the robots.txt even when it disallows the whole site")
 
 	// Bind command-line flags to Viper
 	RobotsCmd.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
@@ -46,62 +64,171 @@ func init() {
 func robotsCmdF(command *cobra.Command, args []string) error {
 	config := config.Config{}
 	viper.Unmarshal(&config)
+	config.ApplySite(viper.IsSet)
 
-	// Fetch the robots.txt from the provided URL
-	resp, err := http.Get(config.Robots.URL)
-	if err != nil {
+	if err := validateRobots(config.Robots); err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	// Read the response body into a string
-	body, err := io.ReadAll(resp.Body)
+	body, err := saveRobots(config.Robots, log.Default())
 	if err != nil {
 		return err
 	}
 
-	modifiedBody := string(body)
+	// Print the output
+	fmt.Println(body)
+	return nil
+}
+
+// saveRobots builds the robots.txt, writes it to the output directory and returns it
+func saveRobots(cfg config.RobotsConfig, logger *log.Logger) (string, error) {
+	rb, err := buildRobots(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	modifiedBody := rb.String()
+
+	// Create a new file
+	path := filepath.Join(cfg.Dir, cfg.File)
+	logger.Printf("Writing robots.txt to %s\n", path)
+	out, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
 
-	if config.Robots.ReplaceURL != "" {
-		// Perform the search and replace operation
-		modifiedBody = strings.ReplaceAll(string(body), config.Robots.URL, config.Robots.ReplaceURL)
+	// Write the modified string to the new file
+	if _, err := out.WriteString(modifiedBody); err != nil {
+		return "", err
 	}
 
-	if config.Robots.ReplaceURL != "" {
-		currentURL, _ := url.Parse(config.Robots.URL)
-		host := currentURL.Host
-		optionList := []string{
-			"http://" + host,
-			"http:\\/\\/" + host,
-			"https://" + host,
-			"https:\\/\\/" + host,
+	return modifiedBody, nil
+}
+
+// buildRobots fetches the live robots.txt, applies the template and rules
+// of the config to it and lists the generated sitemaps.
+// The settings must have been checked by validateRobots
+func buildRobots(cfg config.RobotsConfig) (*robots.Robots, error) {
+	rb := &robots.Robots{}
+	if cfg.URL != "" {
+		live, err := fetchRobots(cfg.URL, cfg.Headers)
+		if err != nil {
+			return nil, err
 		}
+		rb = live
 
-		for _, option := range optionList {
-			// Find the matching string
-			if !strings.Contains(modifiedBody, option) {
-				continue
-			}
+		if cfg.ReplaceURL != "" {
+			rb.RewriteURLs(hostRewriter(cfg.URL, cfg.ReplaceURL))
+		}
+		if !cfg.KeepSitemaps {
+			rb.Sitemaps = nil
+		}
+	}
 
-			// Replace the string
-			modifiedBody = strings.ReplaceAll(modifiedBody, option, config.Robots.ReplaceURL)
+	// The template and the rules of the flags are applied together, so
+	// that override keeps both for the same user agent
+	template := &robots.Robots{}
+	if cfg.Template != "" {
+		f, err := os.Open(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("error opening robots template: %v", err)
+		}
+		defer f.Close()
+
+		template, err = robots.Parse(f)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	// Print the output
-	fmt.Println(modifiedBody)
+	if len(cfg.Allow) > 0 || len(cfg.Disallow) > 0 {
+		group := robots.Group{UserAgents: []string{cfg.UserAgent}}
+		for _, path := range cfg.Allow {
+			group.Rules = append(group.Rules, robots.Rule{Allow: true, Path: path})
+		}
+		for _, path := range cfg.Disallow {
+			group.Rules = append(group.Rules, robots.Rule{Path: path})
+		}
+		template.Merge(&robots.Robots{Groups: []robots.Group{group}})
+	}
 
-	// Create a new file
-	out, err := os.Create(filepath.Join(config.Robots.Dir, config.Robots.File))
+	if cfg.Mode == robotsModeOverride {
+		rb.Override(template)
+	} else {
+		rb.Merge(template)
+	}
+
+	locs, err := sitemapLocs(cfg)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for _, loc := range locs {
+		rb.AddSitemap(loc)
 	}
-	defer out.Close()
 
-	// Write the modified string to the new file
-	if _, err := out.WriteString(modifiedBody); err != nil {
-		return err
+	if agents := rb.DisallowsAll(); len(agents) > 0 && !cfg.AllowDisallowAll {
+		return nil, fmt.Errorf("robots.txt disallows the whole site for %s, use --allow-disallow-all to write it anyway", strings.Join(agents, ", "))
 	}
 
-	return nil
+	return rb, nil
+}
+
+// fetchRobots downloads and parses the robots.txt at the URL
+func fetchRobots(robotsURL string, headers map[string]string) (*robots.Robots, error) {
+	req, err := http.NewRequest(http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching %s: %s", robotsURL, resp.Status)
+	}
+
+	return robots.Parse(resp.Body)
+}
+
+// sitemapLocs returns the absolute URLs of the sitemaps to list, the ones
+// of --sitemap or else the files of the sitemap command found in the directory
+func sitemapLocs(cfg config.RobotsConfig) ([]string, error) {
+	files := cfg.Sitemap
+	if len(files) == 0 {
+		for _, file := range defaultSitemapFiles {
+			if _, err := os.Stat(filepath.Join(cfg.Dir, file)); err == nil {
+				files = append(files, file)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	base := cfg.ReplaceURL
+	if base == "" {
+		siteURL, err := url.Parse(cfg.URL)
+		if err != nil || siteURL.Host == "" {
+			return nil, fmt.Errorf("replace-url or url is required to list the sitemaps")
+		}
+		base = siteURL.Scheme + "://" + siteURL.Host
+	}
+	base = strings.TrimSuffix(base, "/")
+
+	var locs []string
+	for _, file := range files {
+		if strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://") {
+			locs = append(locs, file)
+			continue
+		}
+		locs = append(locs, base+"/"+strings.TrimPrefix(file, "/"))
+	}
+	return locs, nil
 }
@@ -15,17 +15,24 @@ func Run(args []string) error {
 
 // RootCmd ..
 var RootCmd = &cobra.Command{
-	Use:   "wp-go-static",
-	Short: "Wordpress Go Static",
-	Long:  `Wordpress Go Static is a tool to download a Wordpress website and make it static`,
+	Use:               "wp-go-static",
+	Short:             "Wordpress Go Static",
+	Long:              `Wordpress Go Static is a tool to download a Wordpress website and make it static`,
+	PersistentPreRunE: loadConfig,
 }
 
+// cfgFile is the configuration file given with --config
+var cfgFile string
+
 func init() {
 	err := viper.BindPFlags(RootCmd.PersistentFlags())
 	if err != nil {
 		panic(err)
 	}
 
+	// Not bound to Viper, the file is read by loadConfig before any command runs
+	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file (YAML or TOML) with a section per command, like scrape: or sitemap:")
+
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
 	viper.SetEnvPrefix("WGS")
 	viper.AutomaticEnv()
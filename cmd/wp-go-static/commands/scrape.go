@@ -1,13 +1,23 @@
 package commands
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 	"wp-go-static/pkg/file"
+	"wp-go-static/pkg/robots"
+	goSitemap "wp-go-static/pkg/sitemap"
+	goURL "wp-go-static/pkg/url"
+	"wp-go-static/pkg/wpapi"
 
 	"github.com/gocolly/colly"
 	"github.com/spf13/cobra"
@@ -16,24 +26,65 @@ import (
 
 	"wp-go-static/internal/cache"
 	"wp-go-static/internal/config"
+	"wp-go-static/internal/filter"
 	"wp-go-static/internal/html"
+	"wp-go-static/internal/manifest"
+	"wp-go-static/internal/pages"
+	"wp-go-static/internal/redirects"
+	"wp-go-static/internal/report"
+	"wp-go-static/internal/retry"
+	"wp-go-static/internal/throttle"
 )
 
 type Scrape struct {
 	urlCache *cache.URLCache
 	c        *colly.Collector
 	domain   string
-	hostname string
-	config   config.Config
+	rewriter *goURL.Rewriter
+	// canonicalizer normalises URLs before they are deduplicated
+	canonicalizer *goURL.Canonicalizer
+	config        config.Config
+	manifest      *manifest.Manifest
+	pages         *pages.Index
+	filter        *filter.Filter
+	throttle      *throttle.Throttle
+	retry         *retry.Policy
+	// failed holds the URLs that could not be downloaded after every retry
+	failed *retry.Report
+	// report holds the result of every crawled URL and the pages linking to it
+	report *report.Report
+	// redirects holds the redirects followed during the crawl
+	redirects *redirects.Recorder
+	// seeds holds the canonical URLs listed in the sitemap
+	seeds []string
+	// robots holds the rules of the robots.txt of the site when they are respected
+	robots *robots.Robots
+	// retries holds the requests waiting for their retry delay
+	retries   []pendingRetry
+	retriesMu sync.Mutex
+	// logger prefixes the lines with the name of the site when it is part of a batch
+	logger *log.Logger
+}
+
+// pendingRetry is a failed request sent again once due
+type pendingRetry struct {
+	request *colly.Request
+	due     time.Time
 }
 
 func NewScrape() *Scrape {
 	return &Scrape{
 		urlCache: &cache.URLCache{URLs: make(map[string]bool)},
 		c:        colly.NewCollector(),
+		logger:   log.Default(),
 	}
 }
 
+// siteLogger returns the logger of a site of a batch, its lines start with the name of the site
+func siteLogger(name string) *log.Logger {
+	return log.New(log.Writer(), "["+name+"] ", log.Flags()|log.Lmsgprefix)
+}
+
 // ScrapeCmd ...
 var ScrapeCmd = &cobra.Command{
 	Use:   "scrape",
@@ -43,19 +94,89 @@ var ScrapeCmd = &cobra.Command{
 
 const (
 	bindFlagScrapePrefix = "scrape"
+
+	// ctxKeyURL is the request context key holding the requested URL
+	ctxKeyURL = "url"
+	// ctxKeyAttempt is the request context key counting the retries of the URL
+	ctxKeyAttempt = "attempt"
+
+	// seedSitemapAuto looks for the sitemap at the usual WordPress locations
+	seedSitemapAuto = "auto"
+
+	// robotsTxtIgnore crawls every URL, robotsTxtRespect skips the ones disallowed by robots.txt
+	robotsTxtIgnore  = "ignore"
+	robotsTxtRespect = "respect"
+
+	// noIndexIgnore treats noindex pages like the other ones, noIndexExclude
+	// exports them but leaves them out of the sitemap, noIndexSkip does not export them
+	noIndexIgnore  = "ignore"
+	noIndexExclude = "exclude"
+	noIndexSkip    = "skip"
 )
 
+// sitemapCandidates are the sitemaps tried in order by --seed-sitemap=auto:
+// WordPress core, Yoast, then the usual name
+var sitemapCandidates = []string{"wp-sitemap.xml", "sitemap_index.xml", "sitemap.xml"}
+
 func init() {
 	// Define command-line flags
 	ScrapeCmd.PersistentFlags().String("dir", "dump", "directory to save downloaded files")
 	ScrapeCmd.PersistentFlags().String("url", "", "URL to scrape")
 	ScrapeCmd.PersistentFlags().String("cache", "", "Cache directory")
+	ScrapeCmd.PersistentFlags().String("state-dir", "", "Directory of the manifest, page index and failed URLs kept between runs, defaults to --dir with -state appended, outside of the deployed files")
 	ScrapeCmd.PersistentFlags().String("replace-url", "", "Replace with a specific url")
+	ScrapeCmd.PersistentFlags().StringSlice("strip-params", []string{"ver", "utm_*", "fbclid", "gclid"}, "Query parameters removed before deduplicating URLs, * matches a prefix")
+	ScrapeCmd.PersistentFlags().Bool("sort-query", true, "Sort query parameters before deduplicating URLs")
+	ScrapeCmd.PersistentFlags().String("query-policy", goURL.QueryPolicyEncode, "How URLs with a query string are saved: encode adds the query to the file name, ignore drops the query, skip does not download them")
 	ScrapeCmd.PersistentFlags().StringSlice("extra-pages", []string{}, "Extra pages to scrape")
+	ScrapeCmd.PersistentFlags().String("seed-sitemap", "", "Scrape the URLs of this sitemap before following links, auto tries wp-sitemap.xml, sitemap_index.xml and sitemap.xml")
+	ScrapeCmd.PersistentFlags().Lookup("seed-sitemap").NoOptDefVal = seedSitemapAuto
+	ScrapeCmd.PersistentFlags().Bool("wp-api", false, "Also scrape the published posts, pages, terms and users listed by the WordPress REST API")
+	ScrapeCmd.PersistentFlags().StringSlice("wp-api-types", wpapi.DefaultTypes, "REST API endpoints to list, add custom post types by their rest base")
+	ScrapeCmd.PersistentFlags().String("wp-api-user", "", "User of the application password, for a REST API restricted to logged in users")
+	ScrapeCmd.PersistentFlags().String("wp-api-password", "", "Application password for the REST API")
+	ScrapeCmd.PersistentFlags().Int("sites-concurrency", 2, "Maximum number of sites of the sites list scraped at once")
+	ScrapeCmd.PersistentFlags().String("sites-report", "", "Write the combined report of the sites list to this JSON file")
+	ScrapeCmd.PersistentFlags().String("robots-txt", robotsTxtIgnore, "ignore crawls every URL, respect skips the URLs disallowed by the robots.txt of the site")
+	ScrapeCmd.PersistentFlags().String("robots-agent", "wp-go-static", "User agent sent with every request and whose robots.txt rules are respected, the rules for * apply when it has none")
+	ScrapeCmd.PersistentFlags().String("noindex", noIndexIgnore, "Pages with a noindex meta robots or X-Robots-Tag: ignore treats them like the others, exclude exports them but leaves them out of the sitemap, skip does not export them")
 	ScrapeCmd.PersistentFlags().Bool("replace", true, "Replace url")
+	ScrapeCmd.PersistentFlags().Bool("relative", false, "Replace same-site urls in HTML attributes and CSS with paths relative to each file, scripts, feeds and canonical or social links keep the replacement url")
 	ScrapeCmd.PersistentFlags().Bool("parallel", false, "Fetch in parallel")
+	ScrapeCmd.PersistentFlags().Int("parallelism", 4, "Maximum number of concurrent requests when fetching in parallel")
+	ScrapeCmd.PersistentFlags().Duration("delay", 0, "Delay between requests to the same domain")
+	ScrapeCmd.PersistentFlags().Duration("random-delay", 0, "Random extra delay added to --delay")
+	ScrapeCmd.PersistentFlags().Float64("max-rps", 0, "Maximum requests per second, 0 is unlimited")
+	ScrapeCmd.PersistentFlags().Duration("max-backoff", 5*time.Minute, "Longest pause when the origin answers 429 or 503")
+	ScrapeCmd.PersistentFlags().Int("retries", 3, "How many times a failed request is tried again")
+	ScrapeCmd.PersistentFlags().Duration("retry-delay", time.Second, "Delay before the first retry, doubled on every attempt")
+	ScrapeCmd.PersistentFlags().Duration("retry-max-delay", 30*time.Second, "Longest delay between retries")
+	ScrapeCmd.PersistentFlags().IntSlice("retry-status", []int{408, 429, 500, 502, 503, 504}, "Status codes that are retried")
+	ScrapeCmd.PersistentFlags().StringSlice("retry-errors", []string{retry.ErrorTimeout, retry.ErrorReset, retry.ErrorRefused, retry.ErrorEOF}, "Network errors that are retried: timeout, reset, refused, eof, dns")
+	ScrapeCmd.PersistentFlags().Bool("replay-failed", false, "Start from the URLs that failed on the previous run instead of the site URL")
+	ScrapeCmd.PersistentFlags().String("report-json", "", "Write the broken URLs and the pages linking to them to this JSON file")
+	ScrapeCmd.PersistentFlags().String("report-junit", "", "Write the crawl results to this JUnit XML file")
+	ScrapeCmd.PersistentFlags().Bool("fail-on-5xx", false, "Exit with an error when any URL returns a 5xx status")
+	ScrapeCmd.PersistentFlags().Int("max-broken", -1, "Exit with an error when more URLs are broken, -1 is no limit")
+	ScrapeCmd.PersistentFlags().String("redirects", "", "Export the redirects of the site as netlify, nginx, apache or html")
+	ScrapeCmd.PersistentFlags().String("redirects-file", "", "File the redirects are written to, defaults to _redirects, redirects.conf or .htaccess in --dir")
 	ScrapeCmd.PersistentFlags().Bool("images", true, "Download images")
+	ScrapeCmd.PersistentFlags().StringSlice("include-types", []string{}, "Only download these MIME types, like image/* or text/html")
+	ScrapeCmd.PersistentFlags().StringSlice("exclude-types", []string{}, "Do not download these MIME types, like video/*")
+	ScrapeCmd.PersistentFlags().StringSlice("include-ext", []string{}, "Only download files with these extensions, pages are always downloaded for their links")
+	ScrapeCmd.PersistentFlags().StringSlice("exclude-ext", []string{}, "Do not download files with these extensions")
+	ScrapeCmd.PersistentFlags().StringSlice("include-glob", []string{}, "Only download URLs matching these globs")
+	ScrapeCmd.PersistentFlags().StringSlice("exclude-glob", []string{}, "Do not download URLs matching these globs")
+	ScrapeCmd.PersistentFlags().StringSlice("include-regex", []string{}, "Only crawl URLs matching these regular expressions")
+	ScrapeCmd.PersistentFlags().StringSlice("exclude-regex", []string{}, "Do not crawl URLs matching these regular expressions")
+	ScrapeCmd.PersistentFlags().Bool("exclude-wp", false, "Do not crawl wp-admin, wp-login.php, replytocom, feed and search URLs")
+	ScrapeCmd.PersistentFlags().StringToString("max-size", map[string]string{}, "Maximum size by extension, MIME type or *, like .mp4=50MB, checked with HEAD requests")
 	ScrapeCmd.PersistentFlags().Bool("check-head", true, "Checks head")
+	ScrapeCmd.PersistentFlags().Bool("incremental", false, "Only rewrite files that changed since the last run")
+	ScrapeCmd.PersistentFlags().Bool("prune", false, "Remove files that were not produced by this run")
+	ScrapeCmd.PersistentFlags().Bool("prune-dry-run", false, "Only list the files that would be pruned")
+	ScrapeCmd.PersistentFlags().String("quarantine", "", "Move pruned files into this directory, outside of --dir, instead of removing them")
+	ScrapeCmd.PersistentFlags().StringSlice("prune-keep", []string{"robots.txt", "sitemap*.xml"}, "Files that are never pruned")
 	// ScrapeCmd.MarkPersistentFlagRequired("url")
 	// Allow passing additional headers as map[string]string
 	ScrapeCmd.PersistentFlags().StringToString("headers", map[string]string{}, "Additional headers")
@@ -69,64 +190,205 @@ func init() {
 }
 
 func scrapeCmdF(command *cobra.Command, args []string) error {
+	config := config.Config{}
+	viper.Unmarshal(&config)
+
+	if len(config.Sites) > 0 {
+		return scrapeSites(config)
+	}
+
+	config.ApplySite(viper.IsSet)
+	if err := validateScrape(config.Scrape); err != nil {
+		return err
+	}
+
 	scrape := NewScrape()
-	viper.Unmarshal(&scrape.config)
+	scrape.config = config
+	return scrapeSite(scrape)
+}
+
+// scrapeSite crawls the site, then exports its redirects, writes its
+// reports and prunes its directory
+func scrapeSite(scrape *Scrape) error {
+	if err := runScrape(scrape); err != nil {
+		return err
+	}
+
+	if err := scrape.exportRedirects(); err != nil {
+		return err
+	}
+
+	if err := scrape.saveReport(); err != nil {
+		return err
+	}
+
+	// Do not prune the output of a broken crawl
+	if err := scrape.report.Check(scrape.config.Scrape.Fail5xx, scrape.config.Scrape.MaxBroken); err != nil {
+		return err
+	}
+
+	if scrape.config.Scrape.Prune || scrape.config.Scrape.PruneDryRun {
+		return scrape.prune()
+	}
 
-	scrape.domain = scrape.config.Scrape.URL
+	return nil
+}
 
-	if scrape.config.Scrape.CheckHead {
-		scrape.c.CheckHead = true
+// runScrape crawls the site and saves the files and the state of the run,
+// leaving the redirects, the reports and the pruning to the caller.
+// The settings must have been checked by validateScrape
+func runScrape(scrape *Scrape) error {
+	if err := resolvePaths(&scrape.config.Scrape); err != nil {
+		return err
 	}
 
 	if scrape.config.Scrape.Cache != "" {
-		log.Println("Using cache directory", scrape.config.Scrape.Cache)
+		scrape.logger.Println("Using cache directory", scrape.config.Scrape.Cache)
 		scrape.c.CacheDir = scrape.config.Scrape.Cache
 	}
 
+	// Identify as the agent whose robots.txt rules are respected
+	if scrape.config.Scrape.RobotsAgent != "" {
+		scrape.c.UserAgent = scrape.config.Scrape.RobotsAgent
+	}
+
 	scrape.c.Async = scrape.config.Scrape.Parallel
 
+	parallelism := 1
+	if scrape.config.Scrape.Parallel {
+		parallelism = scrape.config.Scrape.Parallelism
+	}
+
+	err := scrape.c.Limit(&colly.LimitRule{
+		DomainGlob:  "*",
+		Parallelism: parallelism,
+		Delay:       scrape.config.Scrape.Delay,
+		RandomDelay: scrape.config.Scrape.RandomDelay,
+	})
+	if err != nil {
+		return err
+	}
+
+	scrape.throttle = throttle.New(scrape.config.Scrape.MaxRPS, scrape.config.Scrape.MaxBackoff)
+
+	scrape.retry, err = retry.New(scrape.config.Scrape)
+	if err != nil {
+		return err
+	}
+	scrape.failed = retry.NewReport(scrape.config.Scrape.StateDir)
+	scrape.report = report.New()
+
+	f, err := filter.New(scrape.config.Scrape)
+	if err != nil {
+		return err
+	}
+	scrape.filter = f
+
+	scrape.pages, err = pages.Load(scrape.config.Scrape.Dir, scrape.config.Scrape.StateDir)
+	if err != nil {
+		return err
+	}
+
+	if scrape.config.Scrape.Incremental {
+		m, err := manifest.Load(scrape.config.Scrape.Dir, scrape.config.Scrape.StateDir)
+		if err != nil {
+			return err
+		}
+		scrape.manifest = m
+	}
+
 	// Use a custom TLS config to verify server certificates
 	scrape.c.WithTransport(&http.Transport{
 		TLSClientConfig: &tls.Config{},
 	})
 
+	scrape.redirects = redirects.NewRecorder()
+
+	// Record every redirect, keeping the default policy of net/http and colly.
+	// The site is checked here and not with AllowedDomains, which colly checks
+	// before calling the handler, so redirects to other hosts are recorded too
+	scrape.c.RedirectHandler = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return http.ErrUseLastResponse
+		}
+
+		lastRequest := via[len(via)-1]
+		if req.Response != nil {
+			from := scrape.canonicalizer.Canonical(lastRequest.URL).String()
+			to := scrape.canonicalizer.Canonical(req.URL).String()
+			scrape.redirects.Add(from, to, req.Response.StatusCode)
+		}
+
+		// Redirects leaving the site are recorded, not followed
+		if !scrape.rewriter.IsSameSite(req.URL.String()) {
+			return http.ErrUseLastResponse
+		}
+
+		for name, values := range lastRequest.Header {
+			for _, value := range values {
+				req.Header.Set(name, value)
+			}
+		}
+		if req.URL.Host != lastRequest.URL.Host {
+			req.Header.Del("Authorization")
+		}
+
+		return nil
+	}
+	scrape.canonicalizer = goURL.NewCanonicalizer(scrape.config.Scrape.StripParams, scrape.config.Scrape.SortQuery)
+
 	parsedURL, err := url.Parse(scrape.config.Scrape.URL)
 	if err != nil {
 		return err
 	}
-	scrape.hostname = parsedURL.Hostname()
+	parsedURL = scrape.canonicalizer.Canonical(parsedURL)
+	scrape.domain = parsedURL.String()
 
-	// Visit only pages that are part of the website
-	scrape.c.AllowedDomains = []string{parsedURL.Host}
+	var queryRewrite *goURL.Canonicalizer
+	if scrape.config.Scrape.QueryPolicy == goURL.QueryPolicyEncode {
+		queryRewrite = scrape.canonicalizer
+	}
+
+	scrape.rewriter, err = goURL.NewRewriter(scrape.config.Scrape.URL, scrape.config.Scrape.ReplaceURL, scrape.config.Scrape.Relative, queryRewrite)
+	if err != nil {
+		return err
+	}
+
+	if scrape.config.Scrape.RobotsTxt == robotsTxtRespect {
+		scrape.loadRobots(parsedURL)
+	}
 
 	for _, extraPage := range scrape.config.Scrape.ExtraPages {
-		log.Println("Visiting Extra Page:", extraPage)
-		scrape.visitURL(extraPage)
+		if scrape.config.Scrape.ReplayFailed {
+			break
+		}
+		scrape.logger.Println("Visiting Extra Page:", extraPage)
+		scrape.visitURL("", extraPage)
 	}
 
 	// On every a element which has href attribute call callback
 	scrape.c.OnHTML("a[href]", func(e *colly.HTMLElement) {
 		link := e.Attr("href")
-		scrape.visitURL(link)
+		scrape.visitURL(pageURL(e.Request), e.Request.AbsoluteURL(link))
 	})
 
 	// On every link element call callback
 	scrape.c.OnHTML("link[href]", func(e *colly.HTMLElement) {
 		link := e.Attr("href")
-		scrape.visitURL(link)
+		scrape.visitURL(pageURL(e.Request), e.Request.AbsoluteURL(link))
 	})
 
 	// On every script element call callback
 	scrape.c.OnHTML("script[src]", func(e *colly.HTMLElement) {
 		link := e.Attr("src")
-		scrape.visitURL(link)
+		scrape.visitURL(pageURL(e.Request), e.Request.AbsoluteURL(link))
 	})
 
 	// On every img element call callback
 	scrape.c.OnHTML("img", func(e *colly.HTMLElement) {
 		src := e.Attr("src")
 		srcSet := e.Attr("srcset")
-		scrape.visitURL(src)
+		scrape.visitURL(pageURL(e.Request), e.Request.AbsoluteURL(src))
 
 		if srcSet != "" {
 			srcSetList := strings.Split(srcSet, ",")
@@ -137,65 +399,693 @@ func scrapeCmdF(command *cobra.Command, args []string) error {
 					continue
 				}
 
-				scrape.visitURL(innerSrcSet)
+				scrape.visitURL(pageURL(e.Request), e.Request.AbsoluteURL(innerSrcSet))
 			}
 		}
 	})
 
 	// Before making a request print "Visiting ..."
 	scrape.c.OnRequest(func(r *colly.Request) {
+		// Keep the requested URL, redirects replace r.URL with the final one.
+		// Retries share the context of the first request
+		if r.Ctx.Get(ctxKeyURL) == "" {
+			r.Ctx.Put(ctxKeyURL, r.URL.String())
+		}
+
+		if scrape.robots != nil && !scrape.robots.Allowed(scrape.config.Scrape.RobotsAgent, r.URL.RequestURI()) {
+			scrape.logger.Printf("Skipping %s: disallowed by robots.txt\n", r.URL.String())
+			r.Abort()
+			return
+		}
+
+		scrape.throttle.Wait()
+
 		// Set headers
 		for headerName, headerValue := range scrape.config.Scrape.Headers {
 			r.Headers.Set(headerName, headerValue)
 		}
 
+		// Send a conditional request when the file is already on disk
+		if scrape.manifest != nil && r.Method == http.MethodGet {
+			if entry, ok := scrape.manifest.Previous(r.URL.String()); ok {
+				if entry.ETag != "" {
+					r.Headers.Set("If-None-Match", entry.ETag)
+				}
+				if entry.LastModified != "" {
+					r.Headers.Set("If-Modified-Since", entry.LastModified)
+				}
+			}
+		}
+
 		switch r.Method {
 		case http.MethodGet:
-			log.Printf("Visiting: %s\n", r.URL.String())
+			scrape.logger.Printf("Visiting: %s\n", r.URL.String())
 		case http.MethodHead:
-			log.Printf("Checking: %s\n", r.URL.String())
+			scrape.logger.Printf("Checking: %s\n", r.URL.String())
 		default:
-			log.Printf("Skipping [%s]: %s\n", r.Method, r.URL.String())
+			scrape.logger.Printf("Skipping [%s]: %s\n", r.Method, r.URL.String())
 		}
 	})
 
 	// On response
 	scrape.c.OnResponse(func(r *colly.Response) {
+		scrape.throttle.Success()
+
+		// HEAD responses have no body, the GET is only sent once the filter allowed
+		// the file. HTML pages are always downloaded, for their links
+		if r.Request.Method == http.MethodHead {
+			if ok, reason := scrape.filter.AllowResponse(r.Request.URL, r.Headers); !ok && !isHTML(r) {
+				scrape.logger.Printf("Skipping %s: %s\n", r.Request.URL.String(), reason)
+				return
+			}
+			scrape.get(pageURL(r.Request))
+			return
+		}
+
+		scrape.report.Add(pageURL(r.Request), r.StatusCode, nil)
+
+		if ok, reason := scrape.filter.AllowResponse(r.Request.URL, r.Headers); !ok {
+			if !isHTML(r) {
+				scrape.logger.Printf("Skipping %s: %s\n", r.Request.URL.String(), reason)
+				return
+			}
+			scrape.logger.Printf("Not saving %s: %s\n", r.Request.URL.String(), reason)
+			// Still follow the links of the page
+			scrape.parseBody(r, "")
+			return
+		}
+
+		noIndex := scrape.config.Scrape.NoIndex != noIndexIgnore && isNoIndex(r)
+		if noIndex && scrape.config.Scrape.NoIndex == noIndexSkip {
+			scrape.logger.Printf("Skipping %s: noindex\n", r.Request.URL.String())
+			// Still follow the links of the page
+			scrape.parseBody(r, "")
+			return
+		}
+
 		rCopy := *r
-		dir, fileName := file.HandleFile(r, scrape.config.Scrape.Dir)
-		rCopy.Body = scrape.parseBody(r.Body)
+		dir, fileName := file.HandleFile(r, scrape.config.Scrape.Dir, scrape.config.Scrape.QueryPolicy == goURL.QueryPolicyEncode)
+		rCopy.Body = scrape.parseBody(r, filepath.Join(dir, fileName))
+
+		if fileName != "" {
+			scrape.urlCache.AddFile(pageURL(r.Request), filepath.Join(dir, fileName))
+			scrape.addPage(r, filepath.Join(dir, fileName), noIndex)
+		}
+
+		if scrape.manifest != nil {
+			status := scrape.manifest.Update(pageURL(r.Request), filepath.Join(dir, fileName), r.Headers, rCopy.Body)
+			if status == manifest.StatusUnchanged {
+				scrape.logger.Printf("Unchanged: %s\n", r.Request.URL.String())
+				return
+			}
+		}
 
 		err := file.SaveFile(&rCopy, dir, fileName)
 		if err != nil {
-			log.Println(err)
+			scrape.logger.Println(err)
+			return
+		}
+	})
+
+	// On error
+	scrape.c.OnError(func(r *colly.Response, err error) {
+		// Redirects leaving the site are exported, not downloaded
+		if target, ok := scrape.externalRedirect(r); ok {
+			scrape.logger.Printf("Not following %s: redirects to %s\n", r.Request.URL.String(), target)
+			return
+		}
+
+		if throttle.IsOverloaded(r.StatusCode) {
+			scrape.backoff(r)
+		}
+
+		if scrape.retry.Retryable(r.StatusCode, err) {
+			scrape.retryRequest(r, err)
 			return
 		}
+
+		scrape.report.Add(pageURL(r.Request), r.StatusCode, err)
+
+		if r.StatusCode == http.StatusNotModified && scrape.manifest != nil {
+			scrape.logger.Printf("Not modified: %s\n", r.Request.URL.String())
+			entry, ok := scrape.manifest.Keep(pageURL(r.Request))
+			if !ok {
+				return
+			}
+
+			scrape.urlCache.AddFile(pageURL(r.Request), filepath.Join(scrape.config.Scrape.Dir, entry.File))
+			scrape.pages.Keep(entry.File)
+
+			// The body is empty, so follow the links found on the previous run
+			for _, link := range entry.Links {
+				scrape.visitURL(pageURL(r.Request), link)
+			}
+		}
 	})
 
-	urlsToVisit := []string{
-		"favicon.ico",
+	if scrape.config.Scrape.ReplayFailed {
+		if err := scrape.replayFailed(); err != nil {
+			return err
+		}
+	} else {
+		if scrape.config.Scrape.SeedSitemap != "" {
+			scrape.visitSitemap()
+		}
+
+		urlsToVisit := []string{
+			"favicon.ico",
+		}
+
+		for _, domain := range urlsToVisit {
+			scrape.visitURL("", domain)
+		}
+
+		// Start scraping, unless the sitemap already listed the site URL
+		if !scrape.urlCache.Get(scrape.domain) {
+			scrape.urlCache.Add(scrape.domain)
+			// Failed requests are retried, checkSiteURL fails the run if the site URL still fails
+			err = scrape.visit(scrape.domain)
+			if err != nil && !isNotModified(err) {
+				scrape.logger.Println(err)
+			}
+		}
+
+		// Pages that are not linked from anywhere are only found through the API
+		if scrape.config.Scrape.WPAPI {
+			if err := scrape.visitAPI(); err != nil {
+				return err
+			}
+		}
+	}
+
+	scrape.wait()
+
+	if scrape.manifest != nil {
+		scrape.printReport()
+		if scrape.config.Scrape.ReplayFailed {
+			scrape.manifest.CarryOver()
+		}
+		if err := scrape.manifest.Save(); err != nil {
+			return err
+		}
 	}
 
-	for _, domain := range urlsToVisit {
-		err = scrape.c.Visit(scrape.domain + "/" + domain)
+	if scrape.config.Scrape.ReplayFailed {
+		scrape.pages.CarryOver()
+	}
+	if err := scrape.pages.Save(); err != nil {
+		return err
+	}
+
+	if len(scrape.seeds) > 0 {
+		scrape.printSitemapReport()
+	}
+
+	for _, failure := range scrape.failed.Failures() {
+		scrape.logger.Printf("Failed: %s (%s)\n", failure.URL, failure.Error)
+	}
+	if err := scrape.failed.Save(); err != nil {
+		return err
+	}
+
+	return scrape.checkSiteURL()
+}
+
+// checkSiteURL returns an error when the site URL could not be downloaded,
+// so nothing is pruned or published from an empty crawl
+func (s *Scrape) checkSiteURL() error {
+	result, ok := s.report.Result(s.domain)
+	if !ok || !result.Broken() {
+		return nil
+	}
+
+	if result.Error != "" {
+		return fmt.Errorf("error downloading %s: %s", s.domain, result.Error)
+	}
+	return fmt.Errorf("error downloading %s: status %d", s.domain, result.Status)
+}
+
+// wait blocks until every request is done. Failed requests are sent again once
+// the crawl is idle and their delay is over, so waiting never blocks the crawl,
+// and the retries can queue new requests in turn
+func (s *Scrape) wait() {
+	for {
+		s.c.Wait()
+
+		s.retriesMu.Lock()
+		retries := s.retries
+		s.retries = nil
+		s.retriesMu.Unlock()
+
+		if len(retries) == 0 {
+			return
+		}
+
+		sort.Slice(retries, func(i, j int) bool {
+			return retries[i].due.Before(retries[j].due)
+		})
+		for _, pending := range retries {
+			time.Sleep(time.Until(pending.due))
+			if err := pending.request.Retry(); err != nil && !isNotModified(err) {
+				s.logger.Println(err)
+			}
+		}
+	}
+}
+
+// resolvePaths makes the output paths absolute, so the files written, the
+// manifest, the page index and the pruning all compare the same paths.
+// The state directory defaults to the one next to the output directory
+func resolvePaths(cfg *config.ScrapeConfig) error {
+	cfg.StateDir = config.StateDir(cfg.Dir, cfg.StateDir)
+
+	for _, path := range []*string{&cfg.Dir, &cfg.Quarantine, &cfg.RedirectsFile, &cfg.StateDir} {
+		if *path == "" {
+			continue
+		}
+
+		abs, err := filepath.Abs(*path)
 		if err != nil {
-			log.Println(err)
+			return fmt.Errorf("error resolving path %s: %v", *path, err)
 		}
+		*path = abs
 	}
+	return nil
+}
+
+// exportRedirects writes the redirects followed during the crawl in the configured format.
+// Redirects between URLs saved to the same file, like trailing slash ones, are left out
+func (s *Scrape) exportRedirects() error {
+	format := s.config.Scrape.Redirects
+	if format == "" {
+		return nil
+	}
+
+	produced := s.urlCache.Files()
 
-	// Start scraping
-	err = scrape.c.Visit(scrape.domain)
+	var rules []redirects.Rule
+	for _, chain := range s.redirects.Chains() {
+		if !redirects.IsRedirect(chain.Status) || !s.rewriter.IsSameSite(chain.From) {
+			continue
+		}
 
+		from, err := url.Parse(chain.From)
+		if err != nil {
+			continue
+		}
+		to, err := url.Parse(chain.To)
+		if err != nil {
+			continue
+		}
+
+		fromFile := s.filePath(from)
+		if s.rewriter.IsSameSite(chain.To) && fromFile == s.filePath(to) {
+			continue
+		}
+
+		s.logger.Printf("Redirect %d: %s -> %s\n", chain.Status, chain.From, chain.To)
+
+		if format == redirects.FormatHTML {
+			path := filepath.Join(s.config.Scrape.Dir, filepath.FromSlash(fromFile))
+			if produced[path] {
+				s.logger.Printf("Not replacing %s with a redirect page\n", path)
+				continue
+			}
+			if err := s.saveRedirectPage(chain.From, path, s.rewriter.For(fromFile, nil)(chain.To)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rule := redirects.Rule{From: from.EscapedPath(), To: s.rewriter.Rewrite(chain.To), Status: chain.Status}
+		if from.RawQuery != "" {
+			rule.Query = strings.Split(from.RawQuery, "&")
+		}
+		rules = append(rules, rule)
+
+		// Links to the URL point to the file its query string is encoded into
+		if rule.Query != nil && s.config.Scrape.QueryPolicy == goURL.QueryPolicyEncode {
+			rules = append(rules, redirects.Rule{From: "/" + fromFile, To: rule.To, Status: chain.Status})
+		}
+	}
+
+	if format == redirects.FormatHTML {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case redirects.FormatNetlify:
+		err = redirects.WriteNetlify(&buf, rules)
+	case redirects.FormatNginx:
+		err = redirects.WriteNginx(&buf, rules)
+	case redirects.FormatApache:
+		err = redirects.WriteApache(&buf, rules)
+	}
 	if err != nil {
 		return err
 	}
 
-	scrape.c.Wait()
+	path := s.redirectsFile()
+	s.logger.Printf("Writing %d redirects to %s\n", len(rules), path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error saving redirects: %v", err)
+	}
+
+	return nil
+}
+
+// saveRedirectPage writes a page redirecting to target and keeps it from being pruned
+func (s *Scrape) saveRedirectPage(link string, path string, target string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+	if err := os.WriteFile(path, redirects.Page(target), 0644); err != nil {
+		return fmt.Errorf("error saving redirect page: %v", err)
+	}
+	s.urlCache.AddFile(link, path)
+	return nil
+}
+
+// redirectsFile returns the file the redirect rules are written to
+func (s *Scrape) redirectsFile() string {
+	if s.config.Scrape.RedirectsFile != "" {
+		return s.config.Scrape.RedirectsFile
+	}
+	return filepath.Join(s.config.Scrape.Dir, redirects.DefaultFile(s.config.Scrape.Redirects))
+}
+
+// filePath returns the path the URL is saved to, relative to the output directory
+func (s *Scrape) filePath(u *url.URL) string {
+	if s.config.Scrape.QueryPolicy != goURL.QueryPolicyEncode {
+		withoutQuery := *u
+		withoutQuery.RawQuery = ""
+		u = &withoutQuery
+	}
+	return goURL.FilePath(u)
+}
+
+// saveReport logs the broken URLs and writes the crawl reports
+func (s *Scrape) saveReport() error {
+	broken := s.report.Broken()
+	for _, result := range broken {
+		s.logger.Printf("Broken: %s (%d) referenced by %s\n", result.URL, result.Status, strings.Join(result.Referers, ", "))
+	}
+	s.logger.Printf("URLs checked: %d, broken: %d\n", len(s.report.Results()), len(broken))
+
+	if s.config.Scrape.ReportJSON != "" {
+		if err := s.report.SaveJSON(s.config.Scrape.ReportJSON); err != nil {
+			return err
+		}
+	}
+
+	if s.config.Scrape.ReportJUnit != "" {
+		if err := s.report.SaveJUnit(s.config.Scrape.ReportJUnit); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-func (s *Scrape) visitURL(link string) {
+// visitSitemap queues the URLs listed in the sitemap
+func (s *Scrape) visitSitemap() {
+	candidates := []string{s.config.Scrape.SeedSitemap}
+	if s.config.Scrape.SeedSitemap == seedSitemapAuto {
+		candidates = sitemapCandidates
+	}
+
+	var locs []string
+	for _, candidate := range candidates {
+		sitemapURL := s.getAbsoluteURL(candidate)
+
+		var err error
+		locs, err = goSitemap.Locs(sitemapURL, s.config.Scrape.Headers)
+		if err != nil {
+			// Keep the URLs of the sitemaps read before the error
+			s.logger.Printf("Error reading sitemap %s: %v\n", sitemapURL, err)
+		}
+
+		if len(locs) > 0 {
+			s.logger.Printf("Found %d URLs in %s\n", len(locs), sitemapURL)
+			break
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, loc := range locs {
+		link := s.canonicalLink(loc)
+		if link == "" || seen[link] {
+			continue
+		}
+		seen[link] = true
+		s.seeds = append(s.seeds, link)
+	}
+
+	for _, link := range s.seeds {
+		s.visitURL("", link)
+	}
+}
+
+// printSitemapReport logs the sitemap URLs that failed, and the ones no page links to
+func (s *Scrape) printSitemapReport() {
+	failed, unlinked := 0, 0
+	for _, seed := range s.seeds {
+		result, ok := s.report.Result(seed)
+		switch {
+		case !ok:
+			failed++
+			s.logger.Printf("Sitemap URL not downloaded: %s\n", seed)
+		case result.Broken():
+			failed++
+			s.logger.Printf("Sitemap URL failed: %s (%d)\n", seed, result.Status)
+		}
+
+		if len(result.Referers) == 0 && seed != s.domain {
+			unlinked++
+			s.logger.Printf("Sitemap URL not linked: %s\n", seed)
+		}
+	}
+
+	s.logger.Printf("Sitemap URLs: %d, failed: %d, not linked: %d\n", len(s.seeds), failed, unlinked)
+}
+
+// visitAPI queues the links listed by the WordPress REST API
+func (s *Scrape) visitAPI() error {
+	client, err := wpapi.New(s.config.Scrape.URL, s.config.Scrape.WPAPIUser, s.config.Scrape.WPAPIPassword, s.config.Scrape.Headers)
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range s.config.Scrape.WPAPITypes {
+		links, err := client.Links(endpoint)
+		if err != nil {
+			// Keep the links of the pages listed before the error
+			s.logger.Println(err)
+		}
+
+		s.logger.Printf("Found %d links in the %s endpoint\n", len(links), endpoint)
+		for _, link := range links {
+			s.visitURL("", link)
+		}
+	}
+
+	return nil
+}
+
+// replayFailed queues the URLs that failed on the previous run
+func (s *Scrape) replayFailed() error {
+	failures, err := retry.LoadFailures(s.config.Scrape.StateDir)
+	if err != nil {
+		return err
+	}
+
+	if len(failures) == 0 {
+		s.logger.Println("No failed URLs to replay")
+		return nil
+	}
+
+	for _, failure := range failures {
+		s.logger.Println("Replaying:", failure.URL)
+		s.visitURL("", failure.URL)
+	}
+
+	return nil
+}
+
+// prune removes the files in the output directory that were not produced by this run
+func (s *Scrape) prune() error {
+	keep := append([]string{}, s.config.Scrape.PruneKeep...)
+	if s.config.Scrape.Redirects != "" && s.config.Scrape.Redirects != redirects.FormatHTML {
+		if rel, err := filepath.Rel(s.config.Scrape.Dir, s.redirectsFile()); err == nil {
+			keep = append(keep, filepath.ToSlash(rel))
+		}
+	}
+	orphans, err := file.Orphans(s.config.Scrape.Dir, s.urlCache.Files(), keep, []string{s.config.Scrape.Quarantine})
+	if err != nil {
+		return err
+	}
+
+	for _, orphan := range orphans {
+		s.logger.Printf("Orphan: %s\n", orphan)
+	}
+
+	if s.config.Scrape.PruneDryRun {
+		s.logger.Printf("Dry run, %d files would be pruned\n", len(orphans))
+		return nil
+	}
+
+	if err := file.RemoveFiles(s.config.Scrape.Dir, orphans, s.config.Scrape.Quarantine); err != nil {
+		return err
+	}
+
+	if s.config.Scrape.Quarantine != "" {
+		s.logger.Printf("Moved %d files to %s\n", len(orphans), s.config.Scrape.Quarantine)
+	} else {
+		s.logger.Printf("Removed %d files\n", len(orphans))
+	}
+
+	return nil
+}
+
+// backoff pauses every request after a 429 or 503 response
+func (s *Scrape) backoff(r *colly.Response) {
+	var retryAfter time.Duration
+	if r.Headers != nil {
+		retryAfter = throttle.ParseRetryAfter(r.Headers.Get("Retry-After"))
+	}
+	pause := s.throttle.Backoff(retryAfter)
+
+	s.logger.Printf("Status %d for %s, pausing for %s\n", r.StatusCode, r.Request.URL.String(), pause)
+}
+
+// retryRequest queues the request to be sent again after the delay of the retry
+// policy, or records it in the failed URL report once every retry is used
+func (s *Scrape) retryRequest(r *colly.Response, err error) {
+	attempt, _ := r.Ctx.GetAny(ctxKeyAttempt).(int)
+	attempt++
+
+	if attempt > s.retry.Attempts() {
+		// Without a HEAD response the filter cannot check the size, but the GET can still succeed
+		if r.Request.Method == http.MethodHead {
+			s.logger.Printf("Checking %s failed: %v\n", r.Request.URL.String(), err)
+			s.get(pageURL(r.Request))
+			return
+		}
+
+		s.logger.Printf("Giving up on %s after %d attempts: %v\n", r.Request.URL.String(), attempt, err)
+		s.report.Add(pageURL(r.Request), r.StatusCode, err)
+		s.failed.Add(retry.Failure{
+			URL:      pageURL(r.Request),
+			Status:   r.StatusCode,
+			Error:    err.Error(),
+			Attempts: attempt,
+		})
+		return
+	}
+	r.Ctx.Put(ctxKeyAttempt, attempt)
+
+	// The throttle already pauses every request after a 429 or 503
+	delay := s.retry.Delay(attempt)
+	if throttle.IsOverloaded(r.StatusCode) {
+		delay = 0
+	}
+
+	s.logger.Printf("Retrying %s in %s (%d/%d): %v\n", r.Request.URL.String(), delay, attempt, s.retry.Attempts(), err)
+
+	s.retriesMu.Lock()
+	defer s.retriesMu.Unlock()
+	s.retries = append(s.retries, pendingRetry{request: r.Request, due: time.Now().Add(delay)})
+}
+
+// printReport logs which files were added, updated or left alone
+func (s *Scrape) printReport() {
+	added := s.manifest.Files(manifest.StatusAdded)
+	updated := s.manifest.Files(manifest.StatusUpdated)
+	unchanged := s.manifest.Files(manifest.StatusUnchanged)
+
+	for _, f := range added {
+		s.logger.Printf("Added: %s\n", f)
+	}
+	for _, f := range updated {
+		s.logger.Printf("Updated: %s\n", f)
+	}
+
+	s.logger.Printf("Files added: %d, updated: %d, unchanged: %d\n", len(added), len(updated), len(unchanged))
+}
+
+// addPage records the HTML page saved to path with its last modification time,
+// taken from the article:modified_time meta tag or the Last-Modified header
+func (s *Scrape) addPage(r *colly.Response, path string, noIndex bool) {
+	if !isHTML(r) {
+		return
+	}
+
+	var lastMod string
+	if doc := html.NewHTML(string(r.Body)); doc != nil {
+		lastMod = doc.Meta("article:modified_time")
+	}
+	if lastMod == "" {
+		if modified, err := http.ParseTime(r.Headers.Get("Last-Modified")); err == nil {
+			lastMod = modified.UTC().Format(time.RFC3339)
+		}
+	}
+
+	// Redirected URLs are listed once, by their target
+	s.pages.Add(s.canonicalizer.Canonical(r.Request.URL).String(), path, lastMod, noIndex)
+}
+
+// isNoIndex reports whether the HTML page asks not to be indexed, with a
+// meta robots tag or an X-Robots-Tag header
+func isNoIndex(r *colly.Response) bool {
+	if robots.NoIndex(r.Headers.Values("X-Robots-Tag")...) {
+		return true
+	}
+
+	if !isHTML(r) {
+		return false
+	}
+	doc := html.NewHTML(string(r.Body))
+	return doc != nil && robots.NoIndex(doc.Meta("robots"))
+}
+
+// loadRobots fetches the robots.txt of the site. When it cannot be read
+// every URL is crawled, as if there was none
+func (s *Scrape) loadRobots(siteURL *url.URL) {
+	robotsURL := siteURL.Scheme + "://" + siteURL.Host + "/robots.txt"
+
+	rb, err := fetchRobots(robotsURL, s.config.Scrape.Headers)
+	if err != nil {
+		s.logger.Printf("Not respecting robots.txt: %v\n", err)
+		return
+	}
+	s.robots = rb
+}
+
+// isNotModified reports whether the error was caused by a 304 response,
+// which colly reports as an error
+func isNotModified(err error) bool {
+	return err.Error() == http.StatusText(http.StatusNotModified)
+}
+
+// isHTML reports whether the response is an HTML page
+func isHTML(r *colly.Response) bool {
+	return r.Headers != nil && strings.Contains(strings.ToLower(r.Headers.Get("Content-Type")), "html")
+}
+
+// pageURL returns the URL that was requested, before any redirect
+func pageURL(r *colly.Request) string {
+	if u := r.Ctx.Get(ctxKeyURL); u != "" {
+		return u
+	}
+	return r.URL.String()
+}
+
+func (s *Scrape) visitURL(referer string, link string) {
 	link = s.getAbsoluteURL(link)
 
 	if link == "" {
@@ -204,57 +1094,152 @@ func (s *Scrape) visitURL(link string) {
 
 	u, err := url.Parse(link)
 	if err != nil {
-		log.Printf("Error parsing URL %s: %s", link, err)
+		s.logger.Printf("Error parsing URL %s: %s", link, err)
 		return
 	}
 
 	if u.Scheme == "" || u.Host == "" {
-		log.Printf("Invalid URL: %s", link)
+		s.logger.Printf("Invalid URL: %s", link)
 		return
 	}
 
 	u.Fragment = ""
+	u = s.canonicalizer.Canonical(u)
 
 	link = u.String()
 
+	if s.manifest != nil && referer != "" {
+		s.manifest.AddLink(referer, link)
+	}
+	s.report.AddReferer(link, referer)
+
+	// Visit only pages that are part of the website
+	if !s.rewriter.IsSameSite(link) {
+		return
+	}
+
 	// Download page if it hasn't been visited before
-	if !s.urlCache.Get(link) {
-		s.urlCache.Add(link)
-		err := s.c.Visit(link)
-		if err != nil {
-			log.Println(err)
-		}
+	if s.urlCache.Get(link) {
+		return
+	}
+	s.urlCache.Add(link)
+
+	if u.RawQuery != "" && s.config.Scrape.QueryPolicy == goURL.QueryPolicySkip {
+		s.logger.Printf("Skipping %s: query string\n", link)
+		return
+	}
+
+	if ok, reason := s.filter.AllowURL(u); !ok {
+		s.logger.Printf("Skipping %s: %s\n", link, reason)
+		return
+	}
+
+	err = s.visit(link)
+	if err != nil && !isNotModified(err) {
+		s.logger.Println(err)
 	}
 }
 
-func (s *Scrape) parseBody(body []byte) []byte {
+// externalRedirect returns the target of a redirect response leaving the site
+func (s *Scrape) externalRedirect(r *colly.Response) (string, bool) {
+	if !redirects.IsRedirect(r.StatusCode) || r.Headers == nil {
+		return "", false
+	}
+
+	target, err := r.Request.URL.Parse(r.Headers.Get("Location"))
+	if err != nil || s.rewriter.IsSameSite(target.String()) {
+		return "", false
+	}
+	return target.String(), true
+}
+
+// visit requests the URL. With check-head a HEAD request checks its type and
+// size first, and the GET is only sent from its response, so the filter
+// always sees the HEAD response before the download, even in parallel
+func (s *Scrape) visit(link string) error {
+	if s.config.Scrape.CheckHead {
+		return s.c.Head(link)
+	}
+	return s.c.Visit(link)
+}
+
+// get downloads the URL whose HEAD request allowed it
+func (s *Scrape) get(link string) {
+	ctx := colly.NewContext()
+	ctx.Put(ctxKeyURL, link)
+
+	err := s.c.Request(http.MethodGet, link, nil, ctx, nil)
+	if err != nil && !isNotModified(err) {
+		s.logger.Println(err)
+	}
+}
+
+// parseBody queues the URLs referenced by the body and rewrites them,
+// path is where the body is saved, used to compute relative links
+func (s *Scrape) parseBody(r *colly.Response, path string) []byte {
 	var urlsToVisit []string
-	htmlParser := html.NewHTML(string(body))
+	var htmlParser *html.HTML
 
-	urlsToVisit = append(urlsToVisit, htmlParser.ExtractImageURLs(htmlParser.ExtractCSS())...)
-	urlsToVisit = append(urlsToVisit, htmlParser.ExtractImageURLs(htmlParser.ExtractURLs())...)
+	contentType := strings.ToLower(r.Headers.Get("Content-Type"))
+	if strings.Contains(contentType, "css") {
+		urlsToVisit = html.ExtractCSSURLs(string(r.Body))
+	} else {
+		htmlParser = html.NewHTML(string(r.Body))
+		for _, css := range htmlParser.ExtractCSS() {
+			urlsToVisit = append(urlsToVisit, html.ExtractCSSURLs(css)...)
+		}
+		urlsToVisit = append(urlsToVisit, htmlParser.ExtractImageURLs(htmlParser.ExtractURLs())...)
+	}
 
-	// Download each one if it hasn't been visited before
+	// Download each one if it hasn't been visited before,
+	// relative references are resolved against the URL of the response
 	for _, url := range urlsToVisit {
-		s.visitURL(url)
+		s.visitURL(pageURL(r.Request), r.Request.AbsoluteURL(url))
 	}
 
-	if s.config.Scrape.Replace {
-		optionList := []string{
-			fmt.Sprintf(`http://%s`, s.hostname),
-			fmt.Sprintf(`http:\/\/%s`, s.hostname),
-			fmt.Sprintf(`https://%s`, s.hostname),
-			fmt.Sprintf(`https:\/\/%s`, s.hostname),
-		}
+	if !s.config.Scrape.Replace {
+		return r.Body
+	}
+
+	file, err := filepath.Rel(s.config.Scrape.Dir, path)
+	if err != nil {
+		file = path
+	}
+	// Only references the browser resolves against the file may be relative,
+	// the ones read by scripts, feeds and crawlers point to the replacement URL
+	rewrite := s.rewriter.For(file, r.Request.URL)
+	absolute := s.rewriter.Rewrite
 
-		for _, option := range optionList {
-			// Replace all occurrences of the base URL with a relative URL
-			replaceBody := strings.ReplaceAll(string(body), option, s.config.Scrape.ReplaceURL)
-			body = []byte(replaceBody)
+	// Rewrite only real URL references, binary files are left untouched
+	switch {
+	case strings.Contains(contentType, "html"):
+		body, err := htmlParser.Rewrite(rewrite, absolute)
+		if err != nil {
+			s.logger.Println(err)
+			return r.Body
 		}
+		return []byte(body)
+	case strings.Contains(contentType, "css"):
+		return []byte(html.RewriteCSS(string(r.Body), rewrite))
+	case strings.Contains(contentType, "javascript"),
+		strings.Contains(contentType, "json"),
+		strings.Contains(contentType, "xml"),
+		strings.HasPrefix(contentType, "text/"):
+		return []byte(html.RewriteText(string(r.Body), absolute))
 	}
 
-	return body
+	return r.Body
+}
+
+// canonicalLink returns the absolute canonical form of the link, the one visitURL caches
+func (s *Scrape) canonicalLink(link string) string {
+	u, err := url.Parse(s.getAbsoluteURL(link))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	u.Fragment = ""
+	return s.canonicalizer.Canonical(u).String()
 }
 
 func (s *Scrape) getAbsoluteURL(inputURL string) string {
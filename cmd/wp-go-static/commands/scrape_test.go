@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"wp-go-static/internal/config"
+	"wp-go-static/internal/manifest"
+	"wp-go-static/internal/pages"
+
+	"github.com/spf13/viper"
+)
+
+// scrapeWith runs the scrape command on the site with the scrape settings,
+// which are restored when the test ends, and returns the output directory
+func scrapeWith(t *testing.T, siteURL string, settings map[string]interface{}) (string, error) {
+	t.Helper()
+
+	dir := t.TempDir()
+	settings["url"] = siteURL
+	settings["dir"] = dir
+
+	for name, value := range settings {
+		key := bindFlagScrapePrefix + "." + name
+		previous := viper.Get(key)
+		viper.Set(key, value)
+		t.Cleanup(func() { viper.Set(key, previous) })
+	}
+
+	return dir, scrapeCmdF(ScrapeCmd, nil)
+}
+
+func TestScrapeRelativeText(t *testing.T) {
+	var siteURL string
+	serve := func(contentType string, body func() string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			io.WriteString(w, body())
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<html><head><meta property="og:url" content="`+siteURL+`/"/>`+
+			`<script src="/app.js"></script></head>`+
+			`<body><a href="/about/">About</a> <a href="/feed/">Feed</a> <a href="/wp-json/data">Data</a></body></html>`)
+	})
+	mux.HandleFunc("/favicon.ico", serve("image/x-icon", func() string { return "" }))
+	mux.HandleFunc("/about/", serve("text/html", func() string { return `<html><body>About</body></html>` }))
+	mux.HandleFunc("/app.js", serve("application/javascript", func() string {
+		return `var u="` + siteURL + `/wp-json/x";`
+	}))
+	mux.HandleFunc("/wp-json/data", serve("application/json", func() string {
+		return `{"url":"` + strings.ReplaceAll(siteURL, "/", `\/`) + `\/about\/"}`
+	}))
+	mux.HandleFunc("/feed/", serve("application/rss+xml", func() string {
+		return `<rss><channel><link>` + siteURL + `/about/</link></channel></rss>`
+	}))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	siteURL = server.URL
+
+	dir, err := scrapeWith(t, server.URL, map[string]interface{}{
+		"replace":     true,
+		"relative":    true,
+		"replace-url": "https://static.example.org",
+	})
+	if err != nil {
+		t.Fatalf("scrape error = %v", err)
+	}
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{file: "index.html", want: `<a href="about/index.html">`},
+		{file: "index.html", want: `<meta property="og:url" content="https://static.example.org/"/>`},
+		{file: "app.js", want: `var u="https://static.example.org/wp-json/x";`},
+		{file: "wp-json/data/index.json", want: `{"url":"https:\/\/static.example.org\/about\/"}`},
+		{file: "feed/index.rss", want: `<link>https://static.example.org/about/</link>`},
+	}
+
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(tt.file)))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !strings.Contains(string(data), tt.want) {
+			t.Errorf("%s = %q, want it to contain %q", tt.file, data, tt.want)
+		}
+	}
+}
+
+func TestScrapeExternalRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<html><body><a href="/ext">ext</a> <a href="/old">old</a></body></html>`)
+	})
+	mux.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/x-icon")
+	})
+	mux.HandleFunc("/ext", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://other.example.com/x", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/about/", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/about/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<html><body>About</body></html>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// A broken URL fails the scrape
+	dir, err := scrapeWith(t, server.URL, map[string]interface{}{
+		"redirects":  "netlify",
+		"max-broken": 0,
+	})
+	if err != nil {
+		t.Fatalf("scrape error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "_redirects"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"/ext https://other.example.com/x 301", "/old /about/ 301"} {
+		if !strings.Contains(string(data), want+"\n") {
+			t.Errorf("_redirects = %q, want a line %q", data, want)
+		}
+	}
+}
+
+func TestScrapeUserAgent(t *testing.T) {
+	var mu sync.Mutex
+	agents := map[string]bool{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		agents[r.UserAgent()] = true
+		mu.Unlock()
+
+		if r.URL.Path == "/robots.txt" {
+			io.WriteString(w, "User-agent: *\nDisallow:\n")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<html><body>Home</body></html>`)
+	}))
+	defer server.Close()
+
+	if _, err := scrapeWith(t, server.URL, map[string]interface{}{"robots-agent": "test-agent"}); err != nil {
+		t.Fatalf("scrape error = %v", err)
+	}
+
+	if !reflect.DeepEqual(agents, map[string]bool{"test-agent": true}) {
+		t.Errorf("user agents = %v, want only test-agent", agents)
+	}
+}
+
+func TestScrapeStateDir(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<html><body><a href="/about/">About</a></body></html>`)
+	}))
+	defer server.Close()
+
+	dir, err := scrapeWith(t, server.URL, map[string]interface{}{"incremental": true})
+	if err != nil {
+		t.Fatalf("scrape error = %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".wp-go-static") {
+			t.Errorf("state file %s written to the output directory", entry.Name())
+		}
+	}
+
+	for _, name := range []string{manifest.FileName, pages.FileName} {
+		if _, err := os.Stat(filepath.Join(dir+"-state", name)); err != nil {
+			t.Errorf("state file %s not written next to the output directory: %v", name, err)
+		}
+	}
+
+	// The settings of the scrape are still set
+	cfg := config.Config{}
+	viper.Unmarshal(&cfg)
+	cfg.Scrape.StateDir = filepath.Join(dir, "state")
+	if err := validateScrape(cfg.Scrape); err == nil || !strings.Contains(err.Error(), "state-dir") {
+		t.Errorf("validateScrape() error = %v, want the state directory inside the output directory refused", err)
+	}
+}
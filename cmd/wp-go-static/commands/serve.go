@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"wp-go-static/internal/config"
+	"wp-go-static/internal/server"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// ServeCmd ...
+var ServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Preview the exported website",
+	RunE:  serveCmdF,
+}
+
+const (
+	bindFlagServePrefix = "serve"
+)
+
+func init() {
+	// Define command-line flags
+	ServeCmd.PersistentFlags().String("dir", "dump", "directory with the exported files")
+	ServeCmd.PersistentFlags().String("addr", "localhost:8080", "Address to listen on")
+	ServeCmd.PersistentFlags().Bool("live-reload", false, "Reload the pages in the browser when the files change")
+	ServeCmd.PersistentFlags().Duration("poll-interval", time.Second, "How often the files are checked for changes with --live-reload")
+
+	// Bind command-line flags to Viper
+	ServeCmd.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		bindFlag := fmt.Sprintf("%s.%s", bindFlagServePrefix, flag.Name)
+		viper.BindPFlag(bindFlag, ServeCmd.PersistentFlags().Lookup(flag.Name))
+	})
+
+	RootCmd.AddCommand(ServeCmd)
+}
+
+func serveCmdF(command *cobra.Command, args []string) error {
+	config := config.Config{}
+	viper.Unmarshal(&config)
+	config.ApplySite(viper.IsSet)
+
+	if err := validateExistingDir("serve.dir", config.Serve.Dir); err != nil {
+		return err
+	}
+	if config.Serve.LiveReload && config.Serve.PollInterval <= 0 {
+		return fmt.Errorf("serve.poll-interval must be positive")
+	}
+
+	srv := server.New(config.Serve.Dir, config.Serve.LiveReload)
+
+	if config.Serve.LiveReload {
+		stop := make(chan struct{})
+		defer close(stop)
+		go srv.Watch(config.Serve.PollInterval, stop)
+	}
+
+	log.Printf("Serving %s on http://%s\n", config.Serve.Dir, config.Serve.Addr)
+	return http.ListenAndServe(config.Serve.Addr, srv)
+}
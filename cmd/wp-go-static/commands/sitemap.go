@@ -2,10 +2,15 @@ package commands
 
 import (
 	"fmt"
+	"log"
 	"net/url"
+	"path"
+	"path/filepath"
 	"strings"
 
 	"wp-go-static/internal/config"
+	"wp-go-static/internal/filter"
+	"wp-go-static/internal/pages"
 	goSitemap "wp-go-static/pkg/sitemap"
 
 	"github.com/spf13/cobra"
@@ -22,6 +27,11 @@ var SitemapCmd = &cobra.Command{
 
 const (
 	bindFlagSitemapPrefix = "sitemap"
+
+	// sitemapSourceLive downloads the sitemap of the live site
+	sitemapSourceLive = "live"
+	// sitemapSourceScrape builds the sitemap from the pages written by scrape
+	sitemapSourceScrape = "scrape"
 )
 
 func init() {
@@ -30,6 +40,13 @@ func init() {
 	SitemapCmd.PersistentFlags().String("url", "", "URL to scrape")
 	SitemapCmd.PersistentFlags().String("replace-url", "", "Replace with a specific url")
 	SitemapCmd.PersistentFlags().String("file", "sitemap.xml", "Output sitemap file name")
+	SitemapCmd.PersistentFlags().String("source", sitemapSourceLive, "Where the URLs come from: live downloads the sitemap of the site, scrape uses the pages written by scrape in --dir")
+	SitemapCmd.PersistentFlags().Bool("mirror", false, "Mirror a live sitemap index: write --index-file and each of its sitemaps instead of merging them into --file")
+	SitemapCmd.PersistentFlags().String("index-file", "sitemap_index.xml", "Output sitemap index file name with --mirror")
+	SitemapCmd.PersistentFlags().Int("concurrency", goSitemap.DefaultConcurrency, "Maximum number of sitemaps of an index fetched at once")
+	SitemapCmd.PersistentFlags().Int("max-urls", goSitemap.MaxURLs, "Maximum number of URLs per sitemap file")
+	SitemapCmd.PersistentFlags().String("max-file-size", "50MB", "Maximum size of a sitemap file")
+	SitemapCmd.PersistentFlags().String("state-dir", "", "Directory of the page index written by scrape with --source scrape, defaults to --dir with -state appended")
 
 	// Bind command-line flags to Viper
 	SitemapCmd.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
@@ -43,31 +60,33 @@ func init() {
 func sitemapCmdF(command *cobra.Command, args []string) error {
 	config := config.Config{}
 	viper.Unmarshal(&config)
+	config.ApplySite(viper.IsSet)
 
-	smap, err := goSitemap.Get(config.Sitemap.URL, nil)
-	if err != nil {
-		fmt.Println(err)
+	if err := validateSitemap(config.Sitemap); err != nil {
+		return err
 	}
 
-	if config.Sitemap.ReplaceURL != "" {
-		currentURL, _ := url.Parse(config.Sitemap.URL)
-		host := currentURL.Host
-		optionList := []string{
-			"http://" + host,
-			"http:\\/\\/" + host,
-			"https://" + host,
-			"https:\\/\\/" + host,
+	if config.Sitemap.Source == sitemapSourceScrape {
+		return scrapedSitemap(config.Sitemap, log.Default())
+	}
+
+	if config.Sitemap.Mirror {
+		idx, smaps, err := goSitemap.GetIndex(config.Sitemap.URL, config.Sitemap.Headers, config.Sitemap.Concurrency)
+		if err != nil {
+			return err
+		}
+		if len(idx.Sitemap) > 0 {
+			return mirrorSitemap(config.Sitemap, idx, smaps)
 		}
+	}
 
-		for i := range smap.URL {
-			for _, option := range optionList {
-				smap.URL[i].Loc = strings.ReplaceAll(smap.URL[i].Loc, option, config.Sitemap.ReplaceURL)
+	smap, err := goSitemap.GetConcurrent(config.Sitemap.URL, config.Sitemap.Headers, config.Sitemap.Concurrency)
+	if err != nil {
+		fmt.Println(err)
+	}
 
-				// for j := range smap.URL[i].Image {
-				// 	smap.URL[i].Image[j].Loc = strings.ReplaceAll(smap.URL[i].Image[j].Loc, option, config.Sitemap.ReplaceURL)
-				// }
-			}
-		}
+	if rewrite := sitemapRewriter(config.Sitemap); rewrite != nil {
+		smap.RewriteURLs(rewrite)
 	}
 
 	// Print the Sitemap
@@ -86,3 +105,156 @@ func sitemapCmdF(command *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// sitemapRewriter returns the function replacing the site URL with the
+// replace URL, or nil when there is no replace URL
+func sitemapRewriter(cfg config.SitemapConfig) func(string) string {
+	if cfg.ReplaceURL == "" {
+		return nil
+	}
+
+	return hostRewriter(cfg.URL, cfg.ReplaceURL)
+}
+
+// hostRewriter returns the function replacing the URLs of the host of the
+// site URL, escaped or not, with the replace URL
+func hostRewriter(siteURL, replaceURL string) func(string) string {
+	currentURL, _ := url.Parse(siteURL)
+	host := currentURL.Host
+	optionList := []string{
+		"http://" + host,
+		"http:\\/\\/" + host,
+		"https://" + host,
+		"https:\\/\\/" + host,
+	}
+
+	return func(u string) string {
+		for _, option := range optionList {
+			u = strings.ReplaceAll(u, option, replaceURL)
+		}
+		return u
+	}
+}
+
+// mirrorSitemap writes the sitemap index and each of its sitemaps as
+// separate files, named after the source ones
+func mirrorSitemap(cfg config.SitemapConfig, idx goSitemap.Index, smaps []goSitemap.Sitemap) error {
+	base := cfg.ReplaceURL
+	if base == "" {
+		siteURL, err := url.Parse(cfg.URL)
+		if err != nil {
+			return fmt.Errorf("error parsing URL: %v", err)
+		}
+		base = siteURL.Scheme + "://" + siteURL.Host
+	}
+	base = strings.TrimSuffix(base, "/")
+
+	rewrite := sitemapRewriter(cfg)
+	used := map[string]bool{cfg.IndexFile: true}
+
+	index := goSitemap.NewIndex()
+	for i, smap := range smaps {
+		file := ""
+		if loc, err := url.Parse(idx.Sitemap[i].Loc); err == nil {
+			file = path.Base(loc.Path)
+		}
+		if file == "" || file == "." || file == "/" || used[file] {
+			file = fmt.Sprintf("sitemap-%d.xml", i+1)
+		}
+		used[file] = true
+
+		if rewrite != nil {
+			smap.RewriteURLs(rewrite)
+		}
+
+		fmt.Printf("Writing %d URLs to %s/%s\n", len(smap.URL), cfg.Dir, file)
+		if err := smap.Save(cfg.Dir, file); err != nil {
+			return err
+		}
+
+		lastMod := idx.Sitemap[i].LastMod
+		if lastMod == "" {
+			lastMod = smap.LastMod()
+		}
+		index.Add(base+"/"+file, lastMod)
+	}
+
+	fmt.Printf("Writing sitemap index to %s/%s\n", cfg.Dir, cfg.IndexFile)
+	return index.Save(cfg.Dir, cfg.IndexFile)
+}
+
+// scrapedSitemap writes the sitemap of the pages written by scrape, split into
+// several files listed by a sitemap index when they do not fit in one.
+// The settings must have been checked by validateSitemap, the progress goes to logger
+func scrapedSitemap(cfg config.SitemapConfig, logger *log.Logger) error {
+	maxBytes, err := filter.ParseSize(cfg.MaxFileSize)
+	if err != nil {
+		return fmt.Errorf("invalid max file size: %v", err)
+	}
+	stateDir := config.StateDir(cfg.Dir, cfg.StateDir)
+	list, err := pages.List(stateDir)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("no scraped pages found in %s, run scrape first", stateDir)
+	}
+
+	base := cfg.ReplaceURL
+	if base == "" {
+		base = cfg.URL
+	}
+	base = strings.TrimSuffix(base, "/")
+
+	var urls []goSitemap.URL
+	noIndex := 0
+	for _, page := range list {
+		if page.NoIndex {
+			noIndex++
+			continue
+		}
+		urls = append(urls, goSitemap.URL{
+			Loc:     base + pageLoc(page.File),
+			LastMod: page.LastMod,
+		})
+	}
+	if noIndex > 0 {
+		logger.Printf("Leaving out %d noindex pages\n", noIndex)
+	}
+
+	smaps, err := goSitemap.Split(urls, cfg.MaxURLs, maxBytes)
+	if err != nil {
+		return err
+	}
+
+	if len(smaps) == 1 {
+		logger.Printf("Writing %d URLs to %s/%s\n", len(urls), cfg.Dir, cfg.File)
+		return smaps[0].Save(cfg.Dir, cfg.File)
+	}
+
+	ext := filepath.Ext(cfg.File)
+	name := strings.TrimSuffix(cfg.File, ext)
+
+	index := goSitemap.NewIndex()
+	for i, smap := range smaps {
+		file := fmt.Sprintf("%s-%d%s", name, i+1, ext)
+		logger.Printf("Writing %d URLs to %s/%s\n", len(smap.URL), cfg.Dir, file)
+		if err := smap.Save(cfg.Dir, file); err != nil {
+			return err
+		}
+		index.Add(base+"/"+file, smap.LastMod())
+	}
+
+	logger.Printf("Writing sitemap index to %s/%s\n", cfg.Dir, cfg.File)
+	return index.Save(cfg.Dir, cfg.File)
+}
+
+// pageLoc returns the escaped URL path of a page file, directory index pages
+// are listed by their directory
+func pageLoc(file string) string {
+	p := "/" + file
+	if file == "index.html" || strings.HasSuffix(file, "/index.html") {
+		p = strings.TrimSuffix(p, "index.html")
+	}
+	return (&url.URL{Path: p}).EscapedPath()
+}
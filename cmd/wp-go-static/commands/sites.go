@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"wp-go-static/internal/batch"
+	"wp-go-static/internal/config"
+
+	"github.com/spf13/viper"
+)
+
+// scrapeSites scrapes every site of the sites list, each with its own
+// collector and URL cache
+func scrapeSites(cfg config.Config) error {
+	configs, results, err := siteConfigs(cfg, func(c *config.Config) error {
+		return validateScrape(c.Scrape)
+	})
+	if err != nil {
+		return err
+	}
+
+	report := batch.Run(results, cfg.Scrape.SitesConcurrency, func(i int, result *batch.Result) error {
+		scrape := NewScrape()
+		scrape.config = configs[i]
+		scrape.logger = siteLogger(result.Name)
+
+		err := scrapeSite(scrape)
+		if scrape.report != nil {
+			summary := scrape.report.Summary()
+			result.Checked, result.Broken = summary.Checked, summary.Broken
+		}
+		return err
+	})
+
+	return finishSites(report, cfg.Scrape.SitesReport)
+}
+
+// buildSites builds every site of the sites list, each with its own
+// collector and URL cache
+func buildSites(cfg config.Config) error {
+	configs, results, err := siteConfigs(cfg, prepareBuild)
+	if err != nil {
+		return err
+	}
+
+	report := batch.Run(results, cfg.Build.SitesConcurrency, func(i int, result *batch.Result) error {
+		scrape, stages, failed := buildSite(configs[i], siteLogger(result.Name))
+
+		if scrape.report != nil {
+			summary := scrape.report.Summary()
+			result.Checked, result.Broken = summary.Checked, summary.Broken
+		}
+		for _, s := range stages {
+			result.Stages = append(result.Stages, batch.Stage{
+				Name:     s.name,
+				Status:   s.status,
+				Detail:   s.detail,
+				Duration: s.duration.Seconds(),
+			})
+		}
+
+		if failed != nil {
+			return fmt.Errorf("%s stage failed: %s", failed.name, failed.detail)
+		}
+		return nil
+	})
+
+	return finishSites(report, cfg.Build.SitesReport)
+}
+
+// siteConfigs returns the configuration of every site of the sites list,
+// checked by prepare, and their empty results. Every setting is checked
+// before any site is exported
+func siteConfigs(cfg config.Config, prepare func(*config.Config) error) ([]config.Config, []batch.Result, error) {
+	var configs []config.Config
+	var results []batch.Result
+	paths := make(map[string]string)
+
+	for i, site := range cfg.Sites {
+		c := cfg.ForSite(site, viper.IsSet)
+		name := site.DisplayName()
+		if name == "" {
+			name = fmt.Sprintf("sites[%d]", i)
+		}
+
+		if err := prepare(&c); err != nil {
+			return nil, nil, fmt.Errorf("site %s: %v", name, err)
+		}
+
+		// Sites sharing a directory or a file would overwrite and prune each other's files
+		for _, path := range sitePaths(c.Scrape) {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("site %s: %v", name, err)
+			}
+			if other, ok := paths[abs]; ok {
+				return nil, nil, fmt.Errorf("sites %s and %s share %s", other, name, path)
+			}
+			paths[abs] = name
+		}
+
+		configs = append(configs, c)
+		results = append(results, batch.Result{Name: name, URL: c.Site.URL, Dir: c.Scrape.Dir})
+	}
+
+	return configs, results, nil
+}
+
+// sitePaths returns the directories and files a site writes to
+func sitePaths(cfg config.ScrapeConfig) []string {
+	var paths []string
+	stateDir := config.StateDir(cfg.Dir, cfg.StateDir)
+	for _, path := range []string{cfg.Dir, cfg.ReportJSON, cfg.ReportJUnit, cfg.RedirectsFile, cfg.Quarantine, cfg.Cache, stateDir} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// finishSites prints and saves the combined report, and fails when any site failed
+func finishSites(report batch.Report, path string) error {
+	report.Print(os.Stdout)
+
+	if path != "" {
+		if err := report.SaveJSON(path); err != nil {
+			return err
+		}
+	}
+
+	return report.Check()
+}
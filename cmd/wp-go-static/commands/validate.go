@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wp-go-static/internal/config"
+	"wp-go-static/internal/filter"
+	"wp-go-static/internal/redirects"
+	goSitemap "wp-go-static/pkg/sitemap"
+	goURL "wp-go-static/pkg/url"
+)
+
+// validateScrape checks the scrape settings before anything is downloaded
+func validateScrape(cfg config.ScrapeConfig) error {
+	if err := validateSiteURL("scrape.url", cfg.URL); err != nil {
+		return err
+	}
+	if err := validateWritableDir("scrape.dir", cfg.Dir); err != nil {
+		return err
+	}
+
+	if cfg.Parallel && cfg.Parallelism < 1 {
+		return fmt.Errorf("scrape.parallelism must be at least 1")
+	}
+	if cfg.MaxRPS < 0 {
+		return fmt.Errorf("scrape.max-rps must not be negative")
+	}
+	if cfg.MaxBroken < -1 {
+		return fmt.Errorf("scrape.max-broken must be -1 or more")
+	}
+
+	if err := goURL.ValidateQueryPolicy(cfg.QueryPolicy); err != nil {
+		return fmt.Errorf("scrape.query-policy: %v", err)
+	}
+	if err := redirects.ValidateFormat(cfg.Redirects); err != nil {
+		return fmt.Errorf("scrape.redirects: %v", err)
+	}
+
+	switch cfg.NoIndex {
+	case noIndexIgnore, noIndexExclude, noIndexSkip:
+	default:
+		return fmt.Errorf("scrape.noindex must be %s, %s or %s, not %q", noIndexIgnore, noIndexExclude, noIndexSkip, cfg.NoIndex)
+	}
+	switch cfg.RobotsTxt {
+	case robotsTxtIgnore, robotsTxtRespect:
+	default:
+		return fmt.Errorf("scrape.robots-txt must be %s or %s, not %q", robotsTxtIgnore, robotsTxtRespect, cfg.RobotsTxt)
+	}
+
+	// Options that cannot be combined
+	if cfg.ReplayFailed && (cfg.Prune || cfg.PruneDryRun) {
+		return fmt.Errorf("scrape.replay-failed cannot be combined with prune, the run does not produce every file")
+	}
+	if cfg.ReplayFailed && (cfg.SeedSitemap != "" || cfg.WPAPI) {
+		return fmt.Errorf("scrape.replay-failed cannot be combined with seed-sitemap or wp-api, only the failed URLs are visited")
+	}
+	if cfg.Quarantine != "" && !cfg.Prune && !cfg.PruneDryRun {
+		return fmt.Errorf("scrape.quarantine requires prune or prune-dry-run")
+	}
+	// Whatever is in the output directory is deployed with the site
+	if cfg.Quarantine != "" && isInside(cfg.Dir, cfg.Quarantine) {
+		return fmt.Errorf("scrape.quarantine must be outside of scrape.dir, it would be deployed")
+	}
+	if cfg.StateDir != "" && isInside(cfg.Dir, cfg.StateDir) {
+		return fmt.Errorf("scrape.state-dir must be outside of scrape.dir, it would be deployed")
+	}
+	if cfg.RedirectsFile != "" && cfg.Redirects == "" {
+		return fmt.Errorf("scrape.redirects-file requires redirects")
+	}
+	if cfg.RedirectsFile != "" && cfg.Redirects == redirects.FormatHTML {
+		return fmt.Errorf("scrape.redirects-file cannot be used with html redirects, they are written as pages")
+	}
+	if (cfg.WPAPIUser != "" || cfg.WPAPIPassword != "") && !cfg.WPAPI {
+		return fmt.Errorf("scrape.wp-api-user and wp-api-password require wp-api")
+	}
+
+	return nil
+}
+
+// validateSitemap checks the sitemap settings
+func validateSitemap(cfg config.SitemapConfig) error {
+	switch cfg.Source {
+	case sitemapSourceLive:
+		if err := validateSiteURL("sitemap.url", cfg.URL); err != nil {
+			return err
+		}
+		if cfg.Mirror && cfg.IndexFile == "" {
+			return fmt.Errorf("sitemap.index-file is required with mirror")
+		}
+		if cfg.Concurrency < 1 {
+			return fmt.Errorf("sitemap.concurrency must be at least 1")
+		}
+	case sitemapSourceScrape:
+		if cfg.URL == "" && cfg.ReplaceURL == "" {
+			return fmt.Errorf("sitemap.replace-url or sitemap.url is required to build absolute sitemap URLs")
+		}
+		if cfg.Mirror {
+			return fmt.Errorf("sitemap.mirror only applies to the %s source", sitemapSourceLive)
+		}
+	default:
+		return fmt.Errorf("sitemap.source must be %s or %s, not %q", sitemapSourceLive, sitemapSourceScrape, cfg.Source)
+	}
+
+	if cfg.File == "" {
+		return fmt.Errorf("sitemap.file is required")
+	}
+	if cfg.MaxURLs < 1 || cfg.MaxURLs > goSitemap.MaxURLs {
+		return fmt.Errorf("sitemap.max-urls must be between 1 and %d", goSitemap.MaxURLs)
+	}
+	if _, err := filter.ParseSize(cfg.MaxFileSize); err != nil {
+		return fmt.Errorf("sitemap.max-file-size: %v", err)
+	}
+
+	return validateWritableDir("sitemap.dir", cfg.Dir)
+}
+
+// validateRobots checks the robots settings
+func validateRobots(cfg config.RobotsConfig) error {
+	if cfg.Mode != robotsModeMerge && cfg.Mode != robotsModeOverride {
+		return fmt.Errorf("robots.mode must be %s or %s, not %q", robotsModeMerge, robotsModeOverride, cfg.Mode)
+	}
+
+	if cfg.URL == "" && cfg.Template == "" {
+		return fmt.Errorf("robots.url or robots.template is required")
+	}
+	if cfg.URL != "" {
+		if err := validateSiteURL("robots.url", cfg.URL); err != nil {
+			return err
+		}
+	}
+	if cfg.Template != "" {
+		if _, err := os.Stat(cfg.Template); err != nil {
+			return fmt.Errorf("robots.template: %v", err)
+		}
+	}
+	if cfg.File == "" {
+		return fmt.Errorf("robots.file is required")
+	}
+
+	return validateWritableDir("robots.dir", cfg.Dir)
+}
+
+// validateExistingDir checks that the directory to read from exists
+func validateExistingDir(key string, dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%s: %v", key, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s: %s is not a directory", key, dir)
+	}
+	return nil
+}
+
+// isInside reports whether path is dir or one of its descendants
+func isInside(dir string, path string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// validateSiteURL checks that the URL is set and absolute
+func validateSiteURL(key string, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s is required", key)
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%s: %v", key, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an absolute http or https URL, not %q", key, value)
+	}
+	return nil
+}
+
+// validateWritableDir checks that files can be written to the directory,
+// creating it when it does not exist
+func validateWritableDir(key string, dir string) error {
+	if dir == "" {
+		return fmt.Errorf("%s is required", key)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("%s is not writable: %v", key, err)
+	}
+
+	f, err := os.CreateTemp(dir, ".wp-go-static-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %v", key, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,127 @@
+package batch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Statuses of a site or of a stage
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// Stage is the outcome of a step of the export of a site
+type Stage struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	Detail   string  `json:"detail,omitempty"`
+	Duration float64 `json:"duration"`
+}
+
+// Result is the outcome of the export of a site
+type Result struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Dir     string `json:"dir"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Checked int    `json:"checked"`
+	Broken  int    `json:"broken"`
+	// Duration is in seconds
+	Duration float64 `json:"duration"`
+	Stages   []Stage `json:"stages,omitempty"`
+}
+
+// Report collects the results of the sites of a batch
+type Report struct {
+	Sites   int      `json:"sites"`
+	Failed  int      `json:"failed"`
+	Results []Result `json:"results"`
+}
+
+// Run calls export for every site, at most concurrency at once, and
+// returns their results in order. export fills the result of the site
+// and returns the error that made it fail
+func Run(results []Result, concurrency int, export func(i int, result *Result) error) Report {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			err := export(i, &results[i])
+			results[i].Duration = time.Since(start).Seconds()
+
+			results[i].Status = StatusOK
+			if err != nil {
+				results[i].Status = StatusFailed
+				results[i].Error = err.Error()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	report := Report{Sites: len(results), Results: results}
+	for _, result := range results {
+		if result.Status == StatusFailed {
+			report.Failed++
+		}
+	}
+	return report
+}
+
+// Print writes one line per site
+func (r Report) Print(w io.Writer) {
+	fmt.Fprintln(w, "Sites summary:")
+	for _, result := range r.Results {
+		detail := fmt.Sprintf("%d URLs, %d broken", result.Checked, result.Broken)
+		if result.Error != "" {
+			detail = result.Error
+		}
+		duration := (time.Duration(result.Duration * float64(time.Second))).Round(time.Millisecond)
+		fmt.Fprintf(w, "  %-24s %-8s %8s  %s\n", result.Name, result.Status, duration, detail)
+	}
+	fmt.Fprintf(w, "%d sites, %d failed\n", r.Sites, r.Failed)
+}
+
+// Check returns an error when any site failed
+func (r Report) Check() error {
+	if r.Failed > 0 {
+		return fmt.Errorf("%d of %d sites failed", r.Failed, r.Sites)
+	}
+	return nil
+}
+
+// SaveJSON writes the report as JSON
+func (r Report) SaveJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding report: %v", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error creating directory: %v", err)
+		}
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error saving report: %v", err)
+	}
+	return nil
+}
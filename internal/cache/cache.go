@@ -4,8 +4,9 @@ import "sync"
 
 // URLCache is a struct to hold the visited URLs
 type URLCache struct {
-	mu   sync.Mutex
-	URLs map[string]bool
+	mu    sync.Mutex
+	URLs  map[string]bool
+	files map[string]string
 }
 
 // Add adds a URL to the cache
@@ -22,3 +23,24 @@ func (c *URLCache) Get(url string) bool {
 	_, ok := c.URLs[url]
 	return ok
 }
+
+// AddFile records the file that was produced for a URL
+func (c *URLCache) AddFile(url string, file string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.files == nil {
+		c.files = make(map[string]string)
+	}
+	c.files[url] = file
+}
+
+// Files returns the set of files produced for the visited URLs
+func (c *URLCache) Files() map[string]bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	files := make(map[string]bool, len(c.files))
+	for _, file := range c.files {
+		files[file] = true
+	}
+	return files
+}
@@ -0,0 +1,212 @@
+package check
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"wp-go-static/internal/html"
+	"wp-go-static/internal/redirects"
+	goURL "wp-go-static/pkg/url"
+)
+
+// Issue is a reference that does not work in the exported site
+type Issue struct {
+	File   string `json:"file"`
+	Ref    string `json:"ref"`
+	Reason string `json:"reason"`
+}
+
+// Reasons of an Issue
+const (
+	ReasonMissing  = "missing file"
+	ReasonLeftover = "points to the original site"
+)
+
+// Checker verifies the references of the files in an output directory
+type Checker struct {
+	dir         string
+	host        string
+	replaceHost string
+	replacePath string
+	// rules are the exported redirects, their sources are not missing
+	rules []redirects.Rule
+}
+
+// New creates a Checker for the output directory. siteURL is the original
+// WordPress URL and replaceURL the URL the export is published at, if any.
+// References to the sources of the rules are redirected, so they resolve
+func New(dir string, siteURL string, replaceURL string, rules []redirects.Rule) (*Checker, error) {
+	c := &Checker{dir: dir, rules: rules}
+
+	if siteURL != "" {
+		u, err := url.Parse(siteURL)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing URL: %v", err)
+		}
+		c.host = goURL.CanonicalHost(u)
+	}
+
+	if replaceURL != "" {
+		u, err := url.Parse(replaceURL)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing URL: %v", err)
+		}
+		c.replaceHost = goURL.CanonicalHost(u)
+		c.replacePath = strings.TrimSuffix(u.Path, "/")
+	}
+
+	return c, nil
+}
+
+// Run walks the output directory, parses every HTML and CSS file and returns
+// the references that do not resolve to a file or still point to the original site
+func (c *Checker) Run() ([]Issue, error) {
+	var issues []Issue
+
+	err := filepath.WalkDir(c.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		var refs []string
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".html", ".htm":
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return fmt.Errorf("error reading file: %v", err)
+			}
+			doc := html.NewHTML(string(data))
+			if doc == nil {
+				return nil
+			}
+			refs = doc.ExtractRefs()
+		case ".css":
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return fmt.Errorf("error reading file: %v", err)
+			}
+			refs = html.ExtractCSSURLs(string(data))
+		default:
+			return nil
+		}
+
+		rel, err := filepath.Rel(c.dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		seen := make(map[string]bool)
+		for _, ref := range refs {
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+
+			if reason := c.checkRef(rel, ref); reason != "" {
+				issues = append(issues, Issue{File: rel, Ref: ref, Reason: reason})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking directory: %v", err)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].File < issues[j].File
+	})
+
+	return issues, nil
+}
+
+// checkRef returns why the reference found in file is broken, or an empty string
+func (c *Checker) checkRef(file string, ref string) string {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ""
+	}
+
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+
+	if u.Host != "" {
+		host := goURL.CanonicalHost(u)
+		if c.host != "" && host == c.host && host != c.replaceHost {
+			return ReasonLeftover
+		}
+		if c.replaceHost == "" || host != c.replaceHost {
+			// External reference
+			return ""
+		}
+	}
+
+	var target string
+	switch {
+	case u.Host != "" || strings.HasPrefix(u.Path, "/"):
+		p := u.Path
+		if c.replacePath != "" && (p == c.replacePath || strings.HasPrefix(p, c.replacePath+"/")) {
+			p = strings.TrimPrefix(p, c.replacePath)
+		}
+		target = p
+	case u.Path == "":
+		// Fragment or query of the file itself
+		return ""
+	default:
+		target = path.Join("/", path.Dir(file), u.Path)
+		if strings.HasSuffix(u.Path, "/") {
+			target += "/"
+		}
+	}
+
+	if c.exists(target, u.RawQuery) || c.redirected(target, u.RawQuery) {
+		return ""
+	}
+	return ReasonMissing
+}
+
+// redirected reports whether an exported redirect matches the site path
+func (c *Checker) redirected(sitePath string, rawQuery string) bool {
+	if len(c.rules) == 0 {
+		return false
+	}
+
+	query, _ := url.ParseQuery(rawQuery)
+	_, _, ok := redirects.Match(c.rules, (&url.URL{Path: sitePath}).EscapedPath(), query)
+	return ok
+}
+
+// exists reports whether the site path resolves to a file, as saved by the scrape command.
+// Directories resolve to their index file, whatever its extension
+func (c *Checker) exists(sitePath string, rawQuery string) bool {
+	candidates := []string{
+		goURL.FilePath(&url.URL{Path: sitePath, RawQuery: rawQuery}),
+		goURL.FilePath(&url.URL{Path: sitePath}),
+		strings.TrimPrefix(sitePath, "/"),
+	}
+
+	for _, candidate := range candidates {
+		info, err := os.Stat(filepath.Join(c.dir, filepath.FromSlash(candidate)))
+		if err == nil && !info.IsDir() {
+			return true
+		}
+		if err == nil && info.IsDir() {
+			if matches, _ := filepath.Glob(filepath.Join(c.dir, filepath.FromSlash(candidate), "index.*")); len(matches) > 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
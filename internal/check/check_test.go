@@ -0,0 +1,78 @@
+package check
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"wp-go-static/internal/redirects"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunRedirects(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []redirects.Rule
+		want  []Issue
+	}{
+		{
+			name: "without redirects",
+			want: []Issue{
+				{File: "index.html", Ref: "/old/", Reason: ReasonMissing},
+				{File: "index.html", Ref: "/post/?p=5", Reason: ReasonMissing},
+			},
+		},
+		{
+			name: "redirected",
+			rules: []redirects.Rule{
+				{From: "/old/", To: "/about/", Status: 301},
+				{From: "/post/", Query: []string{"p=5"}, To: "/about/", Status: 301},
+			},
+		},
+		{
+			name: "other query",
+			rules: []redirects.Rule{
+				{From: "/old/", To: "/about/", Status: 301},
+				{From: "/post/", Query: []string{"p=6"}, To: "/about/", Status: 301},
+			},
+			want: []Issue{
+				{File: "index.html", Ref: "/post/?p=5", Reason: ReasonMissing},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				"index.html":       `<a href="/about/">About</a><a href="/old/">Old</a><a href="/post/?p=5">Post</a>`,
+				"about/index.html": `<p>About</p>`,
+			})
+
+			c, err := New(dir, "https://example.com", "", tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Run() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,36 +1,326 @@
 package config
 
+import (
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// unsafeFileChars are the characters replaced in the name of a site to use it in a file name
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
 type Config struct {
+	Site    SiteConfig    `mapstructure:"site"`
+	Sites   []SiteEntry   `mapstructure:"sites"`
+	Build   BuildConfig   `mapstructure:"build"`
 	Scrape  ScrapeConfig  `mapstructure:"scrape"`
 	Sitemap SitemapConfig `mapstructure:"sitemap"`
 	Robots  RobotsConfig  `mapstructure:"robots"`
+	Check   CheckConfig   `mapstructure:"check"`
+	Serve   ServeConfig   `mapstructure:"serve"`
 }
 
-type SitemapConfig struct {
-	Dir        string            `mapstructure:"dir"`
+// SiteConfig holds the settings of the site, shared by every command that
+// embeds it. The site section of the configuration file fills them in for
+// the commands that do not set them, see ApplySite
+type SiteConfig struct {
 	URL        string            `mapstructure:"url"`
+	Dir        string            `mapstructure:"dir"`
 	ReplaceURL string            `mapstructure:"replace-url"`
-	File       string            `mapstructure:"file"`
 	Headers    map[string]string `mapstructure:"headers"`
 }
 
+// FilterConfig holds the settings that choose which URLs are downloaded and saved
+type FilterConfig struct {
+	IncludeTypes     []string          `mapstructure:"include-types"`
+	ExcludeTypes     []string          `mapstructure:"exclude-types"`
+	IncludeExt       []string          `mapstructure:"include-ext"`
+	ExcludeExt       []string          `mapstructure:"exclude-ext"`
+	IncludeGlob      []string          `mapstructure:"include-glob"`
+	ExcludeGlob      []string          `mapstructure:"exclude-glob"`
+	IncludeRegex     []string          `mapstructure:"include-regex"`
+	ExcludeRegex     []string          `mapstructure:"exclude-regex"`
+	ExcludeWordPress bool              `mapstructure:"exclude-wp"`
+	MaxSize          map[string]string `mapstructure:"max-size"`
+}
+
+// SiteEntry is a site of the sites list, with its own filters
+type SiteEntry struct {
+	Name         string `mapstructure:"name"`
+	SiteConfig   `mapstructure:",squash"`
+	FilterConfig `mapstructure:",squash"`
+}
+
+// BatchConfig holds the settings of the commands that export the sites list
+type BatchConfig struct {
+	SitesConcurrency int    `mapstructure:"sites-concurrency"`
+	SitesReport      string `mapstructure:"sites-report"`
+}
+
+type BuildConfig struct {
+	BatchConfig `mapstructure:",squash"`
+	Sitemap     bool   `mapstructure:"sitemap"`
+	Robots      bool   `mapstructure:"robots"`
+	Redirects   string `mapstructure:"redirects"`
+	StateDir    string `mapstructure:"state-dir"`
+}
+
+type SitemapConfig struct {
+	SiteConfig  `mapstructure:",squash"`
+	File        string `mapstructure:"file"`
+	Source      string `mapstructure:"source"`
+	MaxURLs     int    `mapstructure:"max-urls"`
+	MaxFileSize string `mapstructure:"max-file-size"`
+	Mirror      bool   `mapstructure:"mirror"`
+	IndexFile   string `mapstructure:"index-file"`
+	Concurrency int    `mapstructure:"concurrency"`
+	StateDir    string `mapstructure:"state-dir"`
+}
+
 type ScrapeConfig struct {
-	Dir        string            `mapstructure:"dir"`
-	URL        string            `mapstructure:"url"`
-	Cache      string            `mapstructure:"cache"`
-	ReplaceURL string            `mapstructure:"replace-url"`
-	Replace    bool              `mapstructure:"replace"`
-	Parallel   bool              `mapstructure:"parallel"`
-	Images     bool              `mapstructure:"images"`
-	CheckHead  bool              `mapstructure:"check-head"`
-	ExtraPages []string          `mapstructure:"extra-pages"`
-	Headers    map[string]string `mapstructure:"headers"`
+	SiteConfig    `mapstructure:",squash"`
+	FilterConfig  `mapstructure:",squash"`
+	BatchConfig   `mapstructure:",squash"`
+	Cache         string        `mapstructure:"cache"`
+	StateDir      string        `mapstructure:"state-dir"`
+	Replace       bool          `mapstructure:"replace"`
+	Relative      bool          `mapstructure:"relative"`
+	Parallel      bool          `mapstructure:"parallel"`
+	Parallelism   int           `mapstructure:"parallelism"`
+	Delay         time.Duration `mapstructure:"delay"`
+	RandomDelay   time.Duration `mapstructure:"random-delay"`
+	MaxRPS        float64       `mapstructure:"max-rps"`
+	MaxBackoff    time.Duration `mapstructure:"max-backoff"`
+	Retries       int           `mapstructure:"retries"`
+	RetryDelay    time.Duration `mapstructure:"retry-delay"`
+	RetryMaxDelay time.Duration `mapstructure:"retry-max-delay"`
+	RetryStatus   []int         `mapstructure:"retry-status"`
+	RetryErrors   []string      `mapstructure:"retry-errors"`
+	ReplayFailed  bool          `mapstructure:"replay-failed"`
+	ReportJSON    string        `mapstructure:"report-json"`
+	ReportJUnit   string        `mapstructure:"report-junit"`
+	Fail5xx       bool          `mapstructure:"fail-on-5xx"`
+	MaxBroken     int           `mapstructure:"max-broken"`
+	Redirects     string        `mapstructure:"redirects"`
+	RedirectsFile string        `mapstructure:"redirects-file"`
+	Images        bool          `mapstructure:"images"`
+	CheckHead     bool          `mapstructure:"check-head"`
+	Incremental   bool          `mapstructure:"incremental"`
+	Prune         bool          `mapstructure:"prune"`
+	PruneDryRun   bool          `mapstructure:"prune-dry-run"`
+	PruneKeep     []string      `mapstructure:"prune-keep"`
+	Quarantine    string        `mapstructure:"quarantine"`
+	StripParams   []string      `mapstructure:"strip-params"`
+	SortQuery     bool          `mapstructure:"sort-query"`
+	QueryPolicy   string        `mapstructure:"query-policy"`
+	ExtraPages    []string      `mapstructure:"extra-pages"`
+	SeedSitemap   string        `mapstructure:"seed-sitemap"`
+	WPAPI         bool          `mapstructure:"wp-api"`
+	WPAPITypes    []string      `mapstructure:"wp-api-types"`
+	WPAPIUser     string        `mapstructure:"wp-api-user"`
+	WPAPIPassword string        `mapstructure:"wp-api-password"`
+	RobotsTxt     string        `mapstructure:"robots-txt"`
+	RobotsAgent   string        `mapstructure:"robots-agent"`
+	NoIndex       string        `mapstructure:"noindex"`
 }
 
+// RobotsConfig is the robots section, its URL is the robots.txt the command starts from
 type RobotsConfig struct {
-	Dir        string            `mapstructure:"dir"`
-	URL        string            `mapstructure:"url"`
-	ReplaceURL string            `mapstructure:"replace-url"`
-	File       string            `mapstructure:"file"`
-	Headers    map[string]string `mapstructure:"headers"`
+	SiteConfig       `mapstructure:",squash"`
+	File             string   `mapstructure:"file"`
+	Template         string   `mapstructure:"template"`
+	Mode             string   `mapstructure:"mode"`
+	Allow            []string `mapstructure:"allow"`
+	Disallow         []string `mapstructure:"disallow"`
+	UserAgent        string   `mapstructure:"user-agent"`
+	Sitemap          []string `mapstructure:"sitemap"`
+	KeepSitemaps     bool     `mapstructure:"keep-sitemaps"`
+	AllowDisallowAll bool     `mapstructure:"allow-disallow-all"`
+}
+
+type CheckConfig struct {
+	SiteConfig    `mapstructure:",squash"`
+	Redirects     string `mapstructure:"redirects"`
+	RedirectsFile string `mapstructure:"redirects-file"`
+}
+
+type ServeConfig struct {
+	Dir          string        `mapstructure:"dir"`
+	Addr         string        `mapstructure:"addr"`
+	LiveReload   bool          `mapstructure:"live-reload"`
+	PollInterval time.Duration `mapstructure:"poll-interval"`
+}
+
+// IsSetFunc reports whether a setting, like scrape.url, is set on the
+// command line, in the environment or in the configuration file
+type IsSetFunc func(key string) bool
+
+// ApplySite fills the site settings of every command with the ones of the
+// site section. A command setting that is set wins over the site one, which
+// wins over the default, and the headers of the site are added to the ones
+// of the command under the same rule, header by header
+func (c *Config) ApplySite(isSet IsSetFunc) {
+	site := c.Site
+
+	sections := []struct {
+		key string
+		cfg *SiteConfig
+	}{
+		{"scrape", &c.Scrape.SiteConfig},
+		{"sitemap", &c.Sitemap.SiteConfig},
+		{"robots", &c.Robots.SiteConfig},
+		{"check", &c.Check.SiteConfig},
+	}
+	for _, section := range sections {
+		siteURL := site.URL
+		// robots starts from the robots.txt of the site
+		if section.key == "robots" && siteURL != "" {
+			siteURL = strings.TrimSuffix(siteURL, "/") + "/robots.txt"
+		}
+
+		applyString(isSet, section.key+".url", &section.cfg.URL, siteURL)
+		applyString(isSet, section.key+".dir", &section.cfg.Dir, site.Dir)
+		applyString(isSet, section.key+".replace-url", &section.cfg.ReplaceURL, site.ReplaceURL)
+		section.cfg.Headers = mergeHeaders(site.Headers, section.cfg.Headers)
+	}
+	applyString(isSet, "serve.dir", &c.Serve.Dir, site.Dir)
+}
+
+// ForSite returns a copy of the configuration exporting a site of the batch.
+// The settings of the site that are set override the shared site section,
+// then the site and its filters are applied to the commands like ApplySite does
+func (c Config) ForSite(entry SiteEntry, isSet IsSetFunc) Config {
+	site := c.Site
+	if entry.URL != "" {
+		site.URL = entry.URL
+	}
+	if entry.Dir != "" {
+		site.Dir = entry.Dir
+	}
+	if entry.ReplaceURL != "" {
+		site.ReplaceURL = entry.ReplaceURL
+	}
+	site.Headers = mergeHeaders(site.Headers, entry.Headers)
+
+	c.Site = site
+	c.Sites = nil
+	c.ApplySite(isSet)
+
+	filters := &c.Scrape.FilterConfig
+	applyList(isSet, "scrape.include-types", &filters.IncludeTypes, entry.IncludeTypes)
+	applyList(isSet, "scrape.exclude-types", &filters.ExcludeTypes, entry.ExcludeTypes)
+	applyList(isSet, "scrape.include-ext", &filters.IncludeExt, entry.IncludeExt)
+	applyList(isSet, "scrape.exclude-ext", &filters.ExcludeExt, entry.ExcludeExt)
+	applyList(isSet, "scrape.include-glob", &filters.IncludeGlob, entry.IncludeGlob)
+	applyList(isSet, "scrape.exclude-glob", &filters.ExcludeGlob, entry.ExcludeGlob)
+	applyList(isSet, "scrape.include-regex", &filters.IncludeRegex, entry.IncludeRegex)
+	applyList(isSet, "scrape.exclude-regex", &filters.ExcludeRegex, entry.ExcludeRegex)
+	if entry.ExcludeWordPress && !isSet("scrape.exclude-wp") {
+		filters.ExcludeWordPress = true
+	}
+	filters.MaxSize = mergeHeaders(entry.MaxSize, filters.MaxSize)
+
+	// The files and directories written outside of the site directory are
+	// shared by the batch, every site gets its own
+	key := entry.key(site.Dir)
+	c.Scrape.ReportJSON = siteFile(c.Scrape.ReportJSON, key)
+	c.Scrape.ReportJUnit = siteFile(c.Scrape.ReportJUnit, key)
+	c.Scrape.RedirectsFile = siteFile(c.Scrape.RedirectsFile, key)
+	c.Scrape.Quarantine = siteDir(c.Scrape.Quarantine, key)
+	c.Scrape.Cache = siteDir(c.Scrape.Cache, key)
+	c.Scrape.StateDir = siteDir(c.Scrape.StateDir, key)
+	c.Sitemap.StateDir = siteDir(c.Sitemap.StateDir, key)
+	c.Build.StateDir = siteDir(c.Build.StateDir, key)
+
+	return c
+}
+
+// DisplayName returns the name of the site, or else the host of its URL
+func (s SiteEntry) DisplayName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	if u, err := url.Parse(s.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return s.URL
+}
+
+// key returns the name of the site usable in a file name, or else the
+// name of its directory
+func (s SiteEntry) key(dir string) string {
+	key := strings.Trim(unsafeFileChars.ReplaceAllString(s.DisplayName(), "-"), "-.")
+	if key == "" {
+		key = filepath.Base(dir)
+	}
+	return key
+}
+
+// siteFile inserts the key of the site before the extension of the file,
+// report.json becomes report-blog.json
+func siteFile(file string, key string) string {
+	if file == "" {
+		return file
+	}
+	ext := filepath.Ext(file)
+	if ext == filepath.Base(file) {
+		return file + "-" + key
+	}
+	return strings.TrimSuffix(file, ext) + "-" + key + ext
+}
+
+// siteDir returns the subdirectory of the site in the directory
+func siteDir(dir string, key string) string {
+	if dir == "" {
+		return dir
+	}
+	return filepath.Join(dir, key)
+}
+
+// StateDir returns the directory of the state kept between the runs exporting dir,
+// like the manifest of incremental scrapes, the failed URLs and the page index.
+// Unless stateDir is set, it is next to the output directory, dump-state for dump,
+// so it is not deployed with the site
+func StateDir(dir string, stateDir string) string {
+	if stateDir != "" {
+		return stateDir
+	}
+
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return filepath.Clean(dir) + "-state"
+}
+
+// applyString sets the command setting to the site one, unless the command sets it
+func applyString(isSet IsSetFunc, key string, setting *string, site string) {
+	if site != "" && !isSet(key) {
+		*setting = site
+	}
+}
+
+// applyList sets the command list to the site one, unless the command sets it
+func applyList(isSet IsSetFunc, key string, setting *[]string, site []string) {
+	if len(site) > 0 && !isSet(key) {
+		*setting = site
+	}
+}
+
+// mergeHeaders returns the headers, or any other map, of the site overridden
+// by the ones of a command
+func mergeHeaders(site map[string]string, command map[string]string) map[string]string {
+	if len(site) == 0 {
+		return command
+	}
+
+	headers := make(map[string]string, len(site)+len(command))
+	for name, value := range site {
+		headers[name] = value
+	}
+	for name, value := range command {
+		headers[name] = value
+	}
+	return headers
 }
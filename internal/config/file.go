@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// FileTypes are the formats of the configuration file, by extension
+var FileTypes = []string{"yaml", "yml", "toml"}
+
+// CheckFile reads the configuration file on its own and checks that it only
+// holds known settings of the right type, so typos are not silently ignored
+func CheckFile(path string) error {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	known := false
+	for _, fileType := range FileTypes {
+		if strings.EqualFold(ext, fileType) {
+			known = true
+		}
+	}
+	if !known {
+		return fmt.Errorf("config file %s must be %s", path, strings.Join(FileTypes, ", "))
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("error reading config file %s: %v", path, err)
+	}
+
+	var c Config
+	if err := v.UnmarshalExact(&c); err != nil {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	return nil
+}
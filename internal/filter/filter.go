@@ -0,0 +1,297 @@
+package filter
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gobwas/glob"
+
+	"wp-go-static/internal/config"
+)
+
+// Filter decides which URLs are downloaded
+type Filter struct {
+	includeTypes []string
+	excludeTypes []string
+	includeExt   []string
+	excludeExt   []string
+	include      []urlPattern
+	exclude      []urlPattern
+	maxSize      map[string]int64
+}
+
+// urlPattern is a compiled glob or regex rule
+type urlPattern struct {
+	kind  string
+	raw   string
+	match func(string) bool
+}
+
+// WordPressExcludes are the dynamic WordPress URLs that have no place in a static export
+var WordPressExcludes = []string{
+	`/wp-admin/`,
+	`/wp-login\.php`,
+	`[?&]replytocom=`,
+	`/feed/?$`,
+	`/(comments/)?feed/(atom|rss|rss2|rdf)/?$`,
+	`[?&]s=`,
+	`/search/`,
+}
+
+// New creates a Filter from the scrape configuration
+func New(cfg config.ScrapeConfig) (*Filter, error) {
+	f := &Filter{
+		includeTypes: lowerList(cfg.IncludeTypes),
+		excludeTypes: lowerList(cfg.ExcludeTypes),
+		includeExt:   extensionList(cfg.IncludeExt),
+		excludeExt:   extensionList(cfg.ExcludeExt),
+		maxSize:      make(map[string]int64),
+	}
+
+	if !cfg.Images {
+		f.excludeTypes = append(f.excludeTypes, "image/*")
+	}
+
+	excludeRegex := cfg.ExcludeRegex
+	if cfg.ExcludeWordPress {
+		excludeRegex = append(excludeRegex, WordPressExcludes...)
+	}
+
+	var err error
+	if f.include, err = compilePatterns(cfg.IncludeGlob, cfg.IncludeRegex); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compilePatterns(cfg.ExcludeGlob, excludeRegex); err != nil {
+		return nil, err
+	}
+
+	for key, value := range cfg.MaxSize {
+		size, err := ParseSize(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max size for %s: %v", key, err)
+		}
+		key = strings.ToLower(key)
+		if !strings.Contains(key, "/") && key != "*" {
+			key = normalizeExtension(key)
+		}
+		f.maxSize[key] = size
+	}
+
+	return f, nil
+}
+
+// AllowURL checks the rules that only need the URL, before it is requested.
+// The MIME type is guessed from the extension when possible
+func (f *Filter) AllowURL(u *url.URL) (bool, string) {
+	if p, ok := matchPattern(f.exclude, u); ok {
+		return false, fmt.Sprintf("excluded by %s %s", p.kind, p.raw)
+	}
+	if _, ok := matchPattern(f.include, u); len(f.include) > 0 && !ok {
+		return false, "not matched by any include rule"
+	}
+
+	ext := strings.ToLower(path.Ext(u.Path))
+	if ext == "" {
+		return true, ""
+	}
+
+	if contains(f.excludeExt, ext) {
+		return false, fmt.Sprintf("extension %s is excluded", ext)
+	}
+
+	// Pages are always fetched for their links, like the ones without an extension,
+	// the response decides whether they are saved
+	if isPageExtension(ext) {
+		return true, ""
+	}
+
+	if len(f.includeExt) > 0 && !contains(f.includeExt, ext) {
+		return false, fmt.Sprintf("extension %s is not included", ext)
+	}
+
+	if mediaType := mime.TypeByExtension(ext); mediaType != "" {
+		return f.allowType(mediaType)
+	}
+
+	return true, ""
+}
+
+// isPageExtension reports whether the extension is the one of an HTML page, static or rendered by PHP
+func isPageExtension(ext string) bool {
+	return ext == ".php" || strings.Contains(mime.TypeByExtension(ext), "html")
+}
+
+// AllowResponse checks the rules that need the response headers,
+// like the ones returned by a HEAD request
+func (f *Filter) AllowResponse(u *url.URL, headers *http.Header) (bool, string) {
+	if headers == nil {
+		return true, ""
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(headers.Get("Content-Type"))
+	mediaType = strings.ToLower(mediaType)
+	if mediaType != "" {
+		if ok, reason := f.allowType(mediaType); !ok {
+			return false, reason
+		}
+	}
+
+	size, err := strconv.ParseInt(headers.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return true, ""
+	}
+
+	if limit, ok := f.sizeLimit(strings.ToLower(path.Ext(u.Path)), mediaType); ok && size > limit {
+		return false, fmt.Sprintf("size %d is over the limit of %d bytes", size, limit)
+	}
+
+	return true, ""
+}
+
+// allowType checks the MIME type rules
+func (f *Filter) allowType(mediaType string) (bool, string) {
+	if matchType(f.excludeTypes, mediaType) {
+		return false, fmt.Sprintf("type %s is excluded", mediaType)
+	}
+	if len(f.includeTypes) > 0 && !matchType(f.includeTypes, mediaType) {
+		return false, fmt.Sprintf("type %s is not included", mediaType)
+	}
+	return true, ""
+}
+
+// sizeLimit returns the most specific size limit: extension, MIME type, MIME wildcard, then *
+func (f *Filter) sizeLimit(ext string, mediaType string) (int64, bool) {
+	if limit, ok := f.maxSize[ext]; ok && ext != "" {
+		return limit, true
+	}
+
+	if limit, ok := f.maxSize[mediaType]; ok && mediaType != "" {
+		return limit, true
+	}
+
+	if major, _, found := strings.Cut(mediaType, "/"); found {
+		if limit, ok := f.maxSize[major+"/*"]; ok {
+			return limit, true
+		}
+	}
+
+	limit, ok := f.maxSize["*"]
+	return limit, ok
+}
+
+// ParseSize parses a size like 500, 200KB, 50MB or 1GB into bytes
+func ParseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			multiplier = unit.size
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			break
+		}
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing size: %v", err)
+	}
+
+	return size * multiplier, nil
+}
+
+// matchType checks the MIME type against patterns like image/png or image/*
+func matchType(patterns []string, mediaType string) bool {
+	for _, pattern := range patterns {
+		if pattern == mediaType || pattern == "*" || pattern == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern checks the full URL and its path against the patterns
+// and returns the first one that matches
+func matchPattern(patterns []urlPattern, u *url.URL) (urlPattern, bool) {
+	full := u.String()
+	requestURI := u.RequestURI()
+	for _, p := range patterns {
+		if p.match(full) || p.match(requestURI) {
+			return p, true
+		}
+	}
+	return urlPattern{}, false
+}
+
+// compilePatterns compiles the glob and regex rules.
+// Globs must match the whole URL or path, regexes can match any part of it
+func compilePatterns(globs []string, regexes []string) ([]urlPattern, error) {
+	var patterns []urlPattern
+	for _, raw := range globs {
+		g, err := glob.Compile(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %s: %v", raw, err)
+		}
+		patterns = append(patterns, urlPattern{kind: "glob", raw: raw, match: g.Match})
+	}
+	for _, raw := range regexes {
+		re, err := regexp.Compile(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %s: %v", raw, err)
+		}
+		patterns = append(patterns, urlPattern{kind: "regex", raw: raw, match: re.MatchString})
+	}
+	return patterns, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerList(list []string) []string {
+	var lower []string
+	for _, item := range list {
+		lower = append(lower, strings.ToLower(strings.TrimSpace(item)))
+	}
+	return lower
+}
+
+func extensionList(list []string) []string {
+	var extensions []string
+	for _, item := range list {
+		extensions = append(extensions, normalizeExtension(item))
+	}
+	return extensions
+}
+
+// normalizeExtension turns mp4, MP4 and .mp4 into .mp4
+func normalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
@@ -0,0 +1,272 @@
+package filter
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"wp-go-static/internal/config"
+)
+
+func TestAllowURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters config.FilterConfig
+		noImage bool
+		url     string
+		want    bool
+	}{
+		{name: "no rules", url: "https://example.com/about/", want: true},
+		{
+			name:    "excluded glob",
+			filters: config.FilterConfig{ExcludeGlob: []string{"/private/*"}},
+			url:     "https://example.com/private/a",
+			want:    false,
+		},
+		{
+			name:    "excluded glob matches the full URL",
+			filters: config.FilterConfig{ExcludeGlob: []string{"https://example.com/tmp/*"}},
+			url:     "https://example.com/tmp/a",
+			want:    false,
+		},
+		{
+			name:    "excluded regex",
+			filters: config.FilterConfig{ExcludeRegex: []string{`\?replytocom=`}},
+			url:     "https://example.com/post/?replytocom=3",
+			want:    false,
+		},
+		{
+			name:    "not included",
+			filters: config.FilterConfig{IncludeGlob: []string{"/blog/*"}},
+			url:     "https://example.com/shop/",
+			want:    false,
+		},
+		{
+			name:    "included",
+			filters: config.FilterConfig{IncludeGlob: []string{"/blog/*"}},
+			url:     "https://example.com/blog/post/",
+			want:    true,
+		},
+		{
+			name:    "exclude wins over include",
+			filters: config.FilterConfig{IncludeGlob: []string{"/blog/*"}, ExcludeGlob: []string{"/blog/draft*"}},
+			url:     "https://example.com/blog/draft-1/",
+			want:    false,
+		},
+		{
+			name:    "excluded extension",
+			filters: config.FilterConfig{ExcludeExt: []string{"MP4"}},
+			url:     "https://example.com/video.mp4",
+			want:    false,
+		},
+		{
+			name:    "extension not included",
+			filters: config.FilterConfig{IncludeExt: []string{".css", "js"}},
+			url:     "https://example.com/a.png",
+			want:    false,
+		},
+		{
+			name:    "pages have no extension",
+			filters: config.FilterConfig{IncludeExt: []string{".css"}},
+			url:     "https://example.com/about/",
+			want:    true,
+		},
+		{
+			name:    "pages are not filtered by extension",
+			filters: config.FilterConfig{IncludeExt: []string{".css"}},
+			url:     "https://example.com/about.html",
+			want:    true,
+		},
+		{
+			name:    "PHP pages are not filtered by extension",
+			filters: config.FilterConfig{IncludeExt: []string{".css"}, IncludeTypes: []string{"text/css"}},
+			url:     "https://example.com/index.php?p=5",
+			want:    true,
+		},
+		{
+			name:    "excluded page extension",
+			filters: config.FilterConfig{ExcludeExt: []string{".php"}},
+			url:     "https://example.com/index.php",
+			want:    false,
+		},
+		{
+			name:    "type guessed from the extension",
+			filters: config.FilterConfig{ExcludeTypes: []string{"video/*"}},
+			url:     "https://example.com/video.mp4",
+			want:    false,
+		},
+		{
+			name:    "html pages are fetched for their links",
+			filters: config.FilterConfig{IncludeTypes: []string{"image/*"}},
+			url:     "https://example.com/page.html",
+			want:    true,
+		},
+		{
+			name:    "images",
+			noImage: true,
+			url:     "https://example.com/a.png",
+			want:    false,
+		},
+		{
+			name:    "wordpress",
+			filters: config.FilterConfig{ExcludeWordPress: true},
+			url:     "https://example.com/wp-login.php",
+			want:    false,
+		},
+		{
+			name:    "wordpress feed",
+			filters: config.FilterConfig{ExcludeWordPress: true},
+			url:     "https://example.com/comments/feed/",
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFilter(t, tt.filters, !tt.noImage)
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, reason := f.AllowURL(u); got != tt.want {
+				t.Errorf("AllowURL(%q) = %v (%s), want %v", tt.url, got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		filters     config.FilterConfig
+		url         string
+		contentType string
+		length      string
+		want        bool
+	}{
+		{name: "no headers", url: "https://example.com/a", want: true},
+		{
+			name:        "excluded type",
+			filters:     config.FilterConfig{ExcludeTypes: []string{"application/pdf"}},
+			url:         "https://example.com/download",
+			contentType: "application/pdf",
+			want:        false,
+		},
+		{
+			name:        "type with parameters",
+			filters:     config.FilterConfig{IncludeTypes: []string{"text/*"}},
+			url:         "https://example.com/",
+			contentType: "Text/HTML; charset=UTF-8",
+			want:        true,
+		},
+		{
+			name:        "over the size of the extension",
+			filters:     config.FilterConfig{MaxSize: map[string]string{".zip": "1KB", "*": "1GB"}},
+			url:         "https://example.com/a.zip",
+			contentType: "application/zip",
+			length:      "2048",
+			want:        false,
+		},
+		{
+			name:        "under the size of the type",
+			filters:     config.FilterConfig{MaxSize: map[string]string{"video/*": "10MB"}},
+			url:         "https://example.com/a.mp4",
+			contentType: "video/mp4",
+			length:      "2048",
+			want:        true,
+		},
+		{
+			name:        "over the default size",
+			filters:     config.FilterConfig{MaxSize: map[string]string{"*": "1KB"}},
+			url:         "https://example.com/a",
+			contentType: "text/html",
+			length:      "2048",
+			want:        false,
+		},
+		{
+			name:        "unknown length",
+			filters:     config.FilterConfig{MaxSize: map[string]string{"*": "1KB"}},
+			url:         "https://example.com/a",
+			contentType: "text/html",
+			want:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFilter(t, tt.filters, true)
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var headers *http.Header
+			if tt.contentType != "" || tt.length != "" {
+				headers = &http.Header{}
+				headers.Set("Content-Type", tt.contentType)
+				headers.Set("Content-Length", tt.length)
+			}
+
+			if got, reason := f.AllowResponse(u, headers); got != tt.want {
+				t.Errorf("AllowResponse(%q) = %v (%s), want %v", tt.url, got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "500", want: 500},
+		{value: "500B", want: 500},
+		{value: "200KB", want: 200 << 10},
+		{value: "50 mb", want: 50 << 20},
+		{value: "1GB", want: 1 << 30},
+		{value: "MB", wantErr: true},
+		{value: "1.5MB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSize(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters config.FilterConfig
+	}{
+		{name: "glob", filters: config.FilterConfig{IncludeGlob: []string{"[a"}}},
+		{name: "regex", filters: config.FilterConfig{ExcludeRegex: []string{"(a"}}},
+		{name: "size", filters: config.FilterConfig{MaxSize: map[string]string{"*": "big"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(config.ScrapeConfig{FilterConfig: tt.filters}); err == nil {
+				t.Errorf("New() accepted an invalid %s", tt.name)
+			}
+		})
+	}
+}
+
+func newTestFilter(t *testing.T, filters config.FilterConfig, images bool) *Filter {
+	t.Helper()
+
+	f, err := New(config.ScrapeConfig{FilterConfig: filters, Images: images})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
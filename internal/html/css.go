@@ -12,6 +12,11 @@ func (h *HTML) ExtractCSS() []string {
 	var cssContents []string
 	var f func(*html.Node)
 	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if style := getAttributeValue(n, "style"); style != "" {
+				cssContents = append(cssContents, style)
+			}
+		}
 		if n.Type == html.ElementNode && (n.Data == "style" || (n.Data == "link" && getAttributeValue(n, "rel") == "stylesheet")) {
 			if n.Data == "style" {
 				for c := n.FirstChild; c != nil; c = c.NextSibling {
@@ -19,10 +24,8 @@ func (h *HTML) ExtractCSS() []string {
 						cssContents = append(cssContents, c.Data)
 					}
 				}
-			} else if n.Data == "link" {
-				// If link tag with stylesheet reference, handle if needed
-				// For now, we're only considering inline styles
 			}
+			// Linked stylesheets are downloaded and parsed on their own, see ExtractCSSURLs
 		}
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
 			f(c)
@@ -74,3 +77,47 @@ func (h *HTML) ExtractURLs() []string {
 
 	return urlList
 }
+
+var (
+	// cssURLRegex matches url() references, quoted or not
+	cssURLRegex = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^'")\s][^)\s]*))\s*\)`)
+
+	// cssImportRegex matches @import rules that use a plain string instead of url()
+	cssImportRegex = regexp.MustCompile(`@import\s+(?:"([^"]*)"|'([^']*)')`)
+)
+
+// RewriteCSS rewrites the url() and @import references of CSS content
+func RewriteCSS(css string, fn RewriteFunc) string {
+	css = replaceCSSRefs(cssURLRegex, css, fn)
+	return replaceCSSRefs(cssImportRegex, css, fn)
+}
+
+// replaceCSSRefs rewrites the reference captured by one of the groups of regex,
+// leaving the rest of the match as it was
+func replaceCSSRefs(regex *regexp.Regexp, css string, fn RewriteFunc) string {
+	return regex.ReplaceAllStringFunc(css, func(match string) string {
+		groups := regex.FindStringSubmatchIndex(match)
+		for i := 2; i < len(groups); i += 2 {
+			if groups[i] < 0 {
+				continue
+			}
+			ref := match[groups[i]:groups[i+1]]
+			return match[:groups[i]] + fn(ref) + match[groups[i+1]:]
+		}
+		return match
+	})
+}
+
+// ExtractCSSURLs extracts the url() and @import references of CSS content.
+// Relative references must be resolved against the URL of the stylesheet
+func ExtractCSSURLs(css string) []string {
+	var urls []string
+	RewriteCSS(css, func(ref string) string {
+		ref = strings.TrimSpace(ref)
+		if ref != "" && !strings.HasPrefix(ref, "#") && !strings.HasPrefix(strings.ToLower(ref), "data:") {
+			urls = append(urls, ref)
+		}
+		return ref
+	})
+	return urls
+}
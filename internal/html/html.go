@@ -24,3 +24,31 @@ func NewHTML(body string) *HTML {
 		htmlNode: doc,
 	}
 }
+
+// Meta returns the content of the first <meta> element whose property or name is key,
+// like article:modified_time
+func (h *HTML) Meta(key string) string {
+	var content string
+	var f func(*html.Node) bool
+	f = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.Data == "meta" {
+			property := getAttributeValue(n, "property")
+			if property == "" {
+				property = getAttributeValue(n, "name")
+			}
+			if strings.EqualFold(property, key) {
+				content = getAttributeValue(n, "content")
+				return true
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if f(c) {
+				return true
+			}
+		}
+		return false
+	}
+	f(h.htmlNode)
+
+	return content
+}
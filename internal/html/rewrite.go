@@ -0,0 +1,170 @@
+package html
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// RewriteFunc returns the new value of a URL reference
+type RewriteFunc func(ref string) string
+
+var (
+	// urlAttributes are the attributes holding a single URL
+	urlAttributes = map[string]bool{
+		"href":       true,
+		"src":        true,
+		"action":     true,
+		"formaction": true,
+		"poster":     true,
+		"cite":       true,
+	}
+
+	// textURLRegex matches absolute and protocol-relative URLs in text, including JSON escaped slashes
+	textURLRegex = regexp.MustCompile(`(?i)(?:https?:)?(?:\\?/){2}[a-z0-9][a-z0-9.-]*(?::\d+)?(?:\\?/[^\s"'<>()\\]*)*`)
+)
+
+// Rewrite walks the parsed document, rewrites every URL reference with fn and
+// returns the rendered HTML. Text content is left untouched.
+// References read by scripts and crawlers rather than resolved against the page,
+// like script contents, social meta tags and canonical or alternate links,
+// are rewritten with absolute instead
+func (h *HTML) Rewrite(fn RewriteFunc, absolute RewriteFunc) (string, error) {
+	h.walkRefs(fn, absolute)
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, h.htmlNode); err != nil {
+		return "", fmt.Errorf("error rendering HTML: %v", err)
+	}
+
+	return buf.String(), nil
+}
+
+// walkRefs replaces every URL reference of the parsed document with the value returned
+// by fn, or by absolute for the references that are not resolved against the page
+func (h *HTML) walkRefs(fn RewriteFunc, absolute RewriteFunc) {
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			attrFn := fn
+			if isAbsoluteRef(n) {
+				attrFn = absolute
+			}
+			for i, attr := range n.Attr {
+				n.Attr[i].Val = rewriteAttribute(n, attr, attrFn)
+			}
+
+			if n.Data == "style" || n.Data == "script" {
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if c.Type != html.TextNode {
+						continue
+					}
+					if n.Data == "style" {
+						c.Data = RewriteCSS(c.Data, fn)
+					} else {
+						c.Data = RewriteText(c.Data, absolute)
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(h.htmlNode)
+}
+
+// isAbsoluteRef reports whether the element references a URL meant to be shared or
+// crawled, like og:url or the canonical link, which must not be relative to the page
+func isAbsoluteRef(n *html.Node) bool {
+	switch n.Data {
+	case "meta":
+		for _, key := range []string{"property", "name"} {
+			value := strings.ToLower(getAttributeValue(n, key))
+			if strings.HasPrefix(value, "og:") || strings.HasPrefix(value, "twitter:") {
+				return true
+			}
+		}
+	case "link":
+		for _, rel := range strings.Fields(strings.ToLower(getAttributeValue(n, "rel"))) {
+			if rel == "canonical" || rel == "alternate" || rel == "shortlink" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rewriteAttribute returns the rewritten value of the attribute
+func rewriteAttribute(n *html.Node, attr html.Attribute, fn RewriteFunc) string {
+	key := strings.ToLower(attr.Key)
+
+	switch {
+	case urlAttributes[key]:
+		return fn(attr.Val)
+	case key == "srcset" || key == "imagesrcset":
+		return RewriteSrcSet(attr.Val, fn)
+	case key == "style":
+		return RewriteCSS(attr.Val, fn)
+	case key == "content" && n.Data == "meta":
+		return RewriteText(attr.Val, fn)
+	case strings.HasPrefix(key, "data-"):
+		return RewriteText(attr.Val, fn)
+	}
+
+	return attr.Val
+}
+
+// RewriteSrcSet rewrites every candidate URL of a srcset value
+func RewriteSrcSet(srcSet string, fn RewriteFunc) string {
+	candidates := strings.Split(srcSet, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = fn(fields[0])
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// RewriteText rewrites the absolute and protocol-relative URLs found in text,
+// like JSON or JavaScript. JSON escaped slashes are kept escaped
+func RewriteText(text string, fn RewriteFunc) string {
+	return textURLRegex.ReplaceAllStringFunc(text, func(match string) string {
+		escaped := strings.Contains(match, `\/`)
+		ref := match
+		if escaped {
+			ref = strings.ReplaceAll(ref, `\/`, `/`)
+		}
+
+		rewritten := fn(ref)
+		if rewritten == ref {
+			return match
+		}
+
+		if escaped {
+			rewritten = strings.ReplaceAll(rewritten, `/`, `\/`)
+		}
+		return rewritten
+	})
+}
+
+// ExtractRefs returns every URL reference of the document, the same ones Rewrite rewrites,
+// without rendering it
+func (h *HTML) ExtractRefs() []string {
+	var refs []string
+	collect := func(ref string) string {
+		trimmed := strings.TrimSpace(ref)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(strings.ToLower(trimmed), "data:") {
+			refs = append(refs, trimmed)
+		}
+		return ref
+	}
+	h.walkRefs(collect, collect)
+	return refs
+}
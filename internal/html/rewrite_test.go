@@ -0,0 +1,80 @@
+package html
+
+import (
+	"strings"
+	"testing"
+
+	goURL "wp-go-static/pkg/url"
+)
+
+func TestRewriteRelative(t *testing.T) {
+	rewriter, err := goURL.NewRewriter("https://example.com", "https://static.example.org", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := rewriter.For("blog/post/index.html", nil)
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "link",
+			body: `<a href="https://example.com/about/">About</a>`,
+			want: `<a href="../../about/index.html">About</a>`,
+		},
+		{
+			name: "stylesheet",
+			body: `<link rel="stylesheet" href="https://example.com/style.css"/>`,
+			want: `<link rel="stylesheet" href="../../style.css"/>`,
+		},
+		{
+			name: "style",
+			body: `<style>body{background:url(https://example.com/bg.png)}</style>`,
+			want: `<style>body{background:url(../../bg.png)}</style>`,
+		},
+		{
+			name: "script",
+			body: `<script>var u="https://example.com/wp-json/x";</script>`,
+			want: `<script>var u="https://static.example.org/wp-json/x";</script>`,
+		},
+		{
+			name: "JSON-LD",
+			body: `<script type="application/ld+json">{"url":"https:\/\/example.com\/about\/"}</script>`,
+			want: `<script type="application/ld+json">{"url":"https:\/\/static.example.org\/about\/"}</script>`,
+		},
+		{
+			name: "og:url",
+			body: `<meta property="og:url" content="https://example.com/"/>`,
+			want: `<meta property="og:url" content="https://static.example.org/"/>`,
+		},
+		{
+			name: "twitter:image",
+			body: `<meta name="twitter:image" content="https://example.com/a.png"/>`,
+			want: `<meta name="twitter:image" content="https://static.example.org/a.png"/>`,
+		},
+		{
+			name: "canonical",
+			body: `<link rel="canonical" href="https://example.com/blog/post/"/>`,
+			want: `<link rel="canonical" href="https://static.example.org/blog/post/"/>`,
+		},
+		{
+			name: "feed",
+			body: `<link rel="alternate" type="application/rss+xml" href="https://example.com/feed/"/>`,
+			want: `<link rel="alternate" type="application/rss+xml" href="https://static.example.org/feed/"/>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewHTML(tt.body).Rewrite(fn, rewriter.Rewrite)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("Rewrite() = %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
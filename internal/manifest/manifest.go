@@ -0,0 +1,232 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// FileName is the name of the manifest file stored in the state directory
+const FileName = ".wp-go-static-manifest.json"
+
+// Status is the result of comparing a downloaded URL with the previous run
+type Status string
+
+const (
+	StatusAdded     Status = "added"
+	StatusUpdated   Status = "updated"
+	StatusUnchanged Status = "unchanged"
+)
+
+// Entry holds the state of a downloaded URL
+type Entry struct {
+	File         string   `json:"file"`
+	ETag         string   `json:"etag,omitempty"`
+	LastModified string   `json:"last_modified,omitempty"`
+	Hash         string   `json:"hash"`
+	Links        []string `json:"links,omitempty"`
+}
+
+// Manifest keeps track of the downloaded URLs between runs
+type Manifest struct {
+	mu sync.Mutex
+	// dir is the output directory the files are relative to, stateDir holds the manifest
+	dir      string
+	stateDir string
+	previous map[string]Entry
+	entries  map[string]*Entry
+	status   map[string]Status
+}
+
+// Load reads the manifest of the output directory dir from the state directory.
+// A missing manifest is not an error, it just means that every URL is new
+func Load(dir string, stateDir string) (*Manifest, error) {
+	m := &Manifest{
+		dir:      dir,
+		stateDir: stateDir,
+		previous: make(map[string]Entry),
+		entries:  make(map[string]*Entry),
+		status:   make(map[string]Status),
+	}
+
+	data, err := os.ReadFile(filepath.Join(stateDir, FileName))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &m.previous); err != nil {
+		return nil, fmt.Errorf("error parsing manifest: %v", err)
+	}
+
+	return m, nil
+}
+
+// Previous returns the entry stored by the previous run for the URL,
+// as long as the file it points to still exists
+func (m *Manifest) Previous(url string) (Entry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.previous[url]
+	if !ok {
+		return Entry{}, false
+	}
+
+	if _, err := os.Stat(filepath.Join(m.dir, entry.File)); err != nil {
+		return Entry{}, false
+	}
+
+	return entry, true
+}
+
+// Update records the response of the URL and returns how it compares with the previous run
+func (m *Manifest) Update(url string, path string, headers *http.Header, body []byte) Status {
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+
+	file, err := filepath.Rel(m.dir, path)
+	if err != nil {
+		file = path
+	}
+
+	prev, exists := m.Previous(url)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.entry(url)
+	entry.File = filepath.ToSlash(file)
+	entry.Hash = hash
+	if headers != nil {
+		entry.ETag = headers.Get("ETag")
+		entry.LastModified = headers.Get("Last-Modified")
+	}
+
+	status := StatusAdded
+	if exists {
+		status = StatusUpdated
+		if prev.Hash == hash && prev.File == entry.File {
+			status = StatusUnchanged
+		}
+	}
+	m.status[url] = status
+
+	return status
+}
+
+// Keep carries over the previous entry of a URL that was not modified
+// and returns it, so the links that were found on it can be followed
+func (m *Manifest) Keep(url string) (Entry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev, ok := m.previous[url]
+	if !ok {
+		return Entry{}, false
+	}
+
+	entry := m.entry(url)
+	entry.File = prev.File
+	entry.Hash = prev.Hash
+	entry.ETag = prev.ETag
+	entry.LastModified = prev.LastModified
+	m.status[url] = StatusUnchanged
+
+	return prev, true
+}
+
+// CarryOver keeps the previous entries of the URLs that were not visited by this run,
+// so a partial run does not drop them from the manifest
+func (m *Manifest) CarryOver() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for url, prev := range m.previous {
+		if entry, ok := m.entries[url]; ok && entry.File != "" {
+			continue
+		}
+		entry := prev
+		m.entries[url] = &entry
+	}
+}
+
+// AddLink records a link found on the URL
+func (m *Manifest) AddLink(url string, link string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.entry(url)
+	for _, l := range entry.Links {
+		if l == link {
+			return
+		}
+	}
+	entry.Links = append(entry.Links, link)
+}
+
+// entry returns the current entry of the URL, creating it if needed.
+// The caller must hold the lock
+func (m *Manifest) entry(url string) *Entry {
+	entry, ok := m.entries[url]
+	if !ok {
+		entry = &Entry{}
+		m.entries[url] = entry
+	}
+	return entry
+}
+
+// Files returns the files with the given status, sorted by name
+func (m *Manifest) Files(status Status) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var files []string
+	for url, s := range m.status {
+		if s == status {
+			files = append(files, m.entries[url].File)
+		}
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+// Save writes the entries of the current run to the state directory
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := make(map[string]Entry)
+	for url, entry := range m.entries {
+		// Links found on pages that failed to download are not useful
+		if entry.File == "" {
+			continue
+		}
+		entries[url] = *entry
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %v", err)
+	}
+
+	if err := os.MkdirAll(m.stateDir, 0755); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(m.stateDir, FileName), data, 0644)
+	if err != nil {
+		return fmt.Errorf("error saving manifest: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package pages
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// FileName is the name of the page index stored in the state directory
+const FileName = ".wp-go-static-pages.json"
+
+// Page is an HTML page written by a scrape
+type Page struct {
+	URL string `json:"url"`
+	// File is the path of the page relative to the output directory
+	File    string `json:"file"`
+	LastMod string `json:"lastmod,omitempty"`
+	// NoIndex is set for pages asking not to be indexed, left out of the sitemap
+	NoIndex bool `json:"noindex,omitempty"`
+}
+
+// Index lists the HTML pages of the output directory, so the sitemap
+// can be built from what was exported instead of the live site
+type Index struct {
+	mu sync.Mutex
+	// dir is the output directory the files are relative to, stateDir holds the index
+	dir      string
+	stateDir string
+	// previous and pages are keyed by file, redirected URLs share the file of their target
+	previous map[string]Page
+	pages    map[string]Page
+}
+
+// Load reads the page index of the output directory dir from the state directory.
+// A missing index is not an error, it just means that there are no pages yet
+func Load(dir string, stateDir string) (*Index, error) {
+	idx := &Index{
+		dir:      dir,
+		stateDir: stateDir,
+		previous: make(map[string]Page),
+		pages:    make(map[string]Page),
+	}
+
+	data, err := os.ReadFile(filepath.Join(stateDir, FileName))
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading page index: %v", err)
+	}
+
+	var list []Page
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("error parsing page index: %v", err)
+	}
+	for _, page := range list {
+		idx.previous[page.File] = page
+	}
+
+	return idx, nil
+}
+
+// Add records a page written to path, a path inside the output directory
+func (idx *Index) Add(url string, path string, lastMod string, noIndex bool) {
+	file, err := filepath.Rel(idx.dir, path)
+	if err != nil {
+		file = path
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	file = filepath.ToSlash(file)
+	idx.pages[file] = Page{URL: url, File: file, LastMod: lastMod, NoIndex: noIndex}
+}
+
+// Keep carries over the page of the previous run saved to file, a path relative
+// to the output directory, for URLs that were not modified
+func (idx *Index) Keep(file string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if page, ok := idx.previous[file]; ok {
+		idx.pages[file] = page
+	}
+}
+
+// CarryOver keeps the pages of the previous run that were not visited by this run,
+// so a partial run does not drop them from the index
+func (idx *Index) CarryOver() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for file, page := range idx.previous {
+		if _, ok := idx.pages[file]; !ok {
+			idx.pages[file] = page
+		}
+	}
+}
+
+// List returns the pages stored in the index of the state directory, sorted by file
+func List(stateDir string) ([]Page, error) {
+	idx, err := Load("", stateDir)
+	if err != nil {
+		return nil, err
+	}
+	return sortPages(idx.previous), nil
+}
+
+// sortPages returns the pages sorted by file
+func sortPages(pages map[string]Page) []Page {
+	list := make([]Page, 0, len(pages))
+	for _, page := range pages {
+		list = append(list, page)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].File < list[j].File
+	})
+	return list
+}
+
+// Save writes the pages of this run to the state directory
+func (idx *Index) Save() error {
+	idx.mu.Lock()
+	list := sortPages(idx.pages)
+	idx.mu.Unlock()
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding page index: %v", err)
+	}
+
+	if err := os.MkdirAll(idx.stateDir, 0755); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(idx.stateDir, FileName), data, 0644); err != nil {
+		return fmt.Errorf("error saving page index: %v", err)
+	}
+
+	return nil
+}
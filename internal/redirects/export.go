@@ -0,0 +1,487 @@
+package redirects
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Formats the redirects can be exported to
+const (
+	FormatNetlify = "netlify"
+	FormatNginx   = "nginx"
+	FormatApache  = "apache"
+	FormatHTML    = "html"
+)
+
+// maxHops stops following redirect chains that loop
+const maxHops = 10
+
+// ValidateFormat checks that the export format is known, an empty format exports nothing
+func ValidateFormat(format string) error {
+	switch format {
+	case "", FormatNetlify, FormatNginx, FormatApache, FormatHTML:
+		return nil
+	}
+	return fmt.Errorf("unknown redirects format: %s", format)
+}
+
+// DefaultFile returns the file the format is written to, relative to the output directory.
+// HTML redirects are written as one page per source path instead
+func DefaultFile(format string) string {
+	switch format {
+	case FormatNetlify:
+		return FileName
+	case FormatNginx:
+		return "redirects.conf"
+	case FormatApache:
+		return ".htaccess"
+	}
+	return ""
+}
+
+// Chain is a redirect from a URL to the final URL of its chain
+type Chain struct {
+	From   string
+	To     string
+	Status int
+	Hops   int
+}
+
+// hop is a single redirect response
+type hop struct {
+	to     string
+	status int
+}
+
+// Recorder collects the redirects followed during a crawl
+type Recorder struct {
+	mu   sync.Mutex
+	hops map[string]hop
+}
+
+// NewRecorder creates an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{hops: make(map[string]hop)}
+}
+
+// Add records that from redirected to to with the status code
+func (r *Recorder) Add(from string, to string, status int) {
+	if from == to {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hops[from] = hop{to: to, status: status}
+}
+
+// Chains returns every recorded source with the final target of its chain, sorted by source.
+// The status is the one of the first redirect, so a temporary redirect stays temporary
+func (r *Recorder) Chains() []Chain {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var chains []Chain
+	for from, first := range r.hops {
+		chain := Chain{From: from, To: first.to, Status: first.status, Hops: 1}
+		for chain.Hops < maxHops {
+			next, ok := r.hops[chain.To]
+			if !ok || next.to == from {
+				break
+			}
+			chain.To = next.to
+			chain.Hops++
+		}
+		chains = append(chains, chain)
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		return chains[i].From < chains[j].From
+	})
+
+	return chains
+}
+
+// RequestURI returns the path of the rule followed by its query conditions
+func (r Rule) RequestURI() string {
+	if len(r.Query) == 0 {
+		return r.From
+	}
+	return r.From + "?" + strings.Join(r.Query, "&")
+}
+
+// WriteNetlify writes the rules in the Netlify _redirects format
+func WriteNetlify(w io.Writer, rules []Rule) error {
+	for _, rule := range rules {
+		fields := append([]string{rule.From}, rule.Query...)
+		fields = append(fields, rule.To, fmt.Sprint(rule.Status))
+		if _, err := fmt.Fprintln(w, strings.Join(fields, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteNginx writes the rules as an nginx map of the request URI to the target,
+// to include in the http block, with the return to add to the server block in a
+// comment. The request URI is compared as a whole, so rules with a query string
+// work as well. nginx cannot return a status held in a variable, so every status
+// other than 301 gets its own map, named after it
+func WriteNginx(w io.Writer, rules []Rule) error {
+	byStatus := make(map[int][]Rule)
+	var statuses []int
+	for _, rule := range rules {
+		if _, ok := byStatus[rule.Status]; !ok {
+			statuses = append(statuses, rule.Status)
+		}
+		byStatus[rule.Status] = append(byStatus[rule.Status], rule)
+	}
+	sort.Ints(statuses)
+
+	var header strings.Builder
+	header.WriteString("# Include this file in the http block, then send the redirects from the server block with:\n")
+	for _, status := range statuses {
+		fmt.Fprintf(&header, "#     if (%s) {\n#         return %d %s;\n#     }\n", nginxVariable(status), status, nginxVariable(status))
+	}
+	if _, err := io.WriteString(w, header.String()); err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		if _, err := fmt.Fprintf(w, "map $request_uri %s {\n", nginxVariable(status)); err != nil {
+			return err
+		}
+		for _, rule := range byStatus[status] {
+			if _, err := fmt.Fprintf(w, "    %s %s;\n", nginxString(rule.RequestURI()), nginxString(rule.To)); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w, "}"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nginxVariable returns the variable the map of the status sets
+func nginxVariable(status int) string {
+	if status == http.StatusMovedPermanently {
+		return "$redirect_target"
+	}
+	return fmt.Sprintf("$redirect_target_%d", status)
+}
+
+// nginxString quotes a value for an nginx configuration file
+func nginxString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(s) + `"`
+}
+
+// WriteApache writes the rules as mod_rewrite rules for an .htaccess file.
+// mod_rewrite matches the decoded path, and the targets are already escaped
+func WriteApache(w io.Writer, rules []Rule) error {
+	if _, err := fmt.Fprintln(w, "RewriteEngine On"); err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if len(rule.Query) > 0 {
+			_, err := fmt.Fprintf(w, "RewriteCond %%{QUERY_STRING} ^%s$\n", regexp.QuoteMeta(strings.Join(rule.Query, "&")))
+			if err != nil {
+				return err
+			}
+		}
+
+		// The target drops the query string unless it has its own
+		to := rule.To
+		if !strings.Contains(to, "?") {
+			to += "?"
+		}
+
+		from := rule.From
+		if decoded, err := url.PathUnescape(from); err == nil {
+			from = decoded
+		}
+
+		pattern := "^" + apachePattern(strings.TrimPrefix(from, "/")) + "$"
+		_, err := fmt.Fprintf(w, "RewriteRule %s %s [R=%d,NE,L]\n", pattern, apacheString(to), rule.Status)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apachePattern quotes the path for a RewriteRule pattern, whitespace would end the argument
+func apachePattern(p string) string {
+	return strings.NewReplacer(" ", `\x20`, "\t", `\t`).Replace(regexp.QuoteMeta(p))
+}
+
+// apacheString escapes the characters mod_rewrite gives a meaning to in a substitution
+func apacheString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, " ", `\ `, "$", `\$`, "%", `\%`).Replace(s)
+}
+
+// LoadFormat reads the rules of a file written in the format, a missing file has no rules.
+// HTML redirects are pages, not rules, so they have none either
+func LoadFormat(path string, format string) ([]Rule, error) {
+	var read func(io.Reader) ([]Rule, error)
+	switch format {
+	case FormatNetlify:
+		read = Parse
+	case FormatNginx:
+		read = ReadNginx
+	case FormatApache:
+		read = ReadApache
+	default:
+		return nil, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading redirects: %v", err)
+	}
+	defer f.Close()
+
+	return read(f)
+}
+
+// ReadNginx reads the rules written by WriteNginx
+func ReadNginx(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	status := 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "map "):
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("invalid nginx map: %s", line)
+			}
+			status = nginxStatus(fields[2])
+		case line == "}":
+			status = 0
+		case status != 0:
+			values, err := nginxStrings(strings.TrimSuffix(line, ";"))
+			if err != nil || len(values) != 2 {
+				return nil, fmt.Errorf("invalid nginx map entry: %s", line)
+			}
+			from, rawQuery, _ := strings.Cut(values[0], "?")
+			rule := Rule{From: from, To: values[1], Status: status}
+			if rawQuery != "" {
+				rule.Query = strings.Split(rawQuery, "&")
+			}
+			rules = append(rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading redirects: %v", err)
+	}
+
+	return rules, nil
+}
+
+// nginxStatus returns the status of the map setting the variable, see nginxVariable
+func nginxStatus(variable string) int {
+	if status, err := strconv.Atoi(strings.TrimPrefix(variable, "$redirect_target_")); err == nil {
+		return status
+	}
+	return http.StatusMovedPermanently
+}
+
+// nginxStrings splits a line into the strings quoted by nginxString
+func nginxStrings(line string) ([]string, error) {
+	var values []string
+	var value strings.Builder
+	quoted, escaped := false, false
+
+	for _, c := range line {
+		switch {
+		case escaped:
+			value.WriteRune(c)
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			if quoted {
+				values = append(values, value.String())
+				value.Reset()
+			}
+			quoted = !quoted
+		case quoted:
+			value.WriteRune(c)
+		case c != ' ' && c != '\t':
+			return nil, fmt.Errorf("unquoted value")
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated string")
+	}
+
+	return values, nil
+}
+
+// ReadApache reads the rules written by WriteApache. The paths are escaped
+// again, like the ones of the other formats
+func ReadApache(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	var query []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := apacheFields(strings.TrimSpace(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "RewriteCond":
+			if len(fields) < 3 || fields[1] != "%{QUERY_STRING}" {
+				continue
+			}
+			query = strings.Split(apacheUnquote(fields[2]), "&")
+		case "RewriteRule":
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("invalid rewrite rule: %s", scanner.Text())
+			}
+			from := "/" + apacheUnquote(fields[1])
+			rule := Rule{
+				From:   (&url.URL{Path: from}).EscapedPath(),
+				Query:  query,
+				To:     strings.TrimSuffix(apacheUnescape(fields[2]), "?"),
+				Status: http.StatusFound,
+			}
+			if len(fields) > 3 {
+				rule.Status = apacheStatus(fields[3], rule.Status)
+			}
+			rules = append(rules, rule)
+			query = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading redirects: %v", err)
+	}
+
+	return rules, nil
+}
+
+// apacheFields splits a line into its arguments, a backslash escapes the next
+// character, which is kept escaped, except for spaces
+func apacheFields(line string) []string {
+	var fields []string
+	var field strings.Builder
+	escaped := false
+
+	for _, c := range line {
+		switch {
+		case escaped:
+			if c != ' ' {
+				field.WriteRune('\\')
+			}
+			field.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == ' ' || c == '\t':
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(c)
+		}
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+
+	return fields
+}
+
+// apacheUnquote returns the text matched by a pattern written by apachePattern
+func apacheUnquote(pattern string) string {
+	return apacheUnescape(strings.TrimSuffix(strings.TrimPrefix(pattern, "^"), "$"))
+}
+
+// apacheUnescape removes the backslashes of an argument, \t and \xNN are decoded
+func apacheUnescape(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '\\' || i == len(pattern)-1 {
+			b.WriteByte(pattern[i])
+			continue
+		}
+
+		i++
+		switch {
+		case pattern[i] == 't':
+			b.WriteByte('\t')
+		case pattern[i] == 'x' && i+2 < len(pattern):
+			if c, err := strconv.ParseUint(pattern[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+			b.WriteByte(pattern[i])
+		default:
+			b.WriteByte(pattern[i])
+		}
+	}
+	return b.String()
+}
+
+// apacheStatus returns the status of the R flag of a rule, like [R=301,NE,L]
+func apacheStatus(flags string, status int) int {
+	for _, flag := range strings.Split(strings.Trim(flags, "[]"), ",") {
+		if value, ok := strings.CutPrefix(flag, "R="); ok {
+			if code, err := strconv.Atoi(value); err == nil {
+				return code
+			}
+		}
+	}
+	return status
+}
+
+// Page returns an HTML page that redirects to the target, for hosts without redirect rules
+func Page(target string) []byte {
+	escaped := html.EscapeString(target)
+	return []byte(fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Redirecting</title>
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url=%s">
+<link rel="canonical" href="%s">
+</head>
+<body>
+<a href="%s">%s</a>
+</body>
+</html>
+`, escaped, escaped, escaped, escaped))
+}
+
+// IsRedirect reports whether the status code is a redirect that can be exported
+func IsRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
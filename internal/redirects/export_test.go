@@ -0,0 +1,95 @@
+package redirects
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWriteRead(t *testing.T) {
+	rules := []Rule{
+		{From: "/", Query: []string{"p=5"}, To: "/hello/", Status: 301},
+		{From: "/a%20b", To: "/c%20d$", Status: 307},
+		{From: "/caf%C3%A9/", To: "https://other.example.com/x?a=1", Status: 302},
+		{From: "/old/", To: "/about/", Status: 301},
+	}
+
+	formats := []struct {
+		name  string
+		write func(io.Writer, []Rule) error
+		read  func(io.Reader) ([]Rule, error)
+	}{
+		{name: FormatNetlify, write: WriteNetlify, read: Parse},
+		{name: FormatNginx, write: WriteNginx, read: ReadNginx},
+		{name: FormatApache, write: WriteApache, read: ReadApache},
+	}
+
+	for _, format := range formats {
+		t.Run(format.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := format.write(&buf, rules); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := format.read(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Slice(got, func(i, j int) bool {
+				return got[i].From < got[j].From
+			})
+			if !reflect.DeepEqual(got, rules) {
+				t.Errorf("read %+v, want %+v", got, rules)
+			}
+		})
+	}
+}
+
+func TestWriteNginx(t *testing.T) {
+	rules := []Rule{
+		{From: "/old/", To: "/about/", Status: 301},
+		{From: "/", Query: []string{"p=5"}, To: "/hello/", Status: 301},
+		{From: "/tmp", To: `/a"$b`, Status: 302},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteNginx(&buf, rules); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"map $request_uri $redirect_target {\n",
+		`    "/old/" "/about/";` + "\n",
+		`    "/?p=5" "/hello/";` + "\n",
+		"map $request_uri $redirect_target_302 {\n",
+		`    "/tmp" "/a\"\$b";` + "\n",
+		"#         return 302 $redirect_target_302;\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("WriteNginx() = %s, want it to contain %q", buf.String(), want)
+		}
+	}
+}
+
+func TestWriteApache(t *testing.T) {
+	rules := []Rule{
+		{From: "/caf%C3%A9/a.b", To: "/about/", Status: 301},
+		{From: "/", Query: []string{"p=5"}, To: "/hello/?x=1", Status: 302},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteApache(&buf, rules); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "RewriteEngine On\n" +
+		"RewriteRule ^café/a\\.b$ /about/? [R=301,NE,L]\n" +
+		"RewriteCond %{QUERY_STRING} ^p=5$\n" +
+		"RewriteRule ^$ /hello/?x=1 [R=302,NE,L]\n"
+	if buf.String() != want {
+		t.Errorf("WriteApache() = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,184 @@
+package redirects
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FileName is the name of the Netlify style redirects file
+const FileName = "_redirects"
+
+// Rule is a redirect from a path to another path or URL.
+// A 200 status rewrites the request instead of redirecting it
+type Rule struct {
+	From string
+	// Query holds the key=value parameters the request must have, values may be :placeholders
+	Query  []string
+	To     string
+	Status int
+	// Force applies the rule even when a file exists at the path
+	Force bool
+}
+
+// Parse reads rules in the Netlify _redirects format: one "from [key=value...] to [status]"
+// rule per line, where from and the values may use :placeholders and from a trailing * splat
+func Parse(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid redirect on line %d: %s", line, text)
+		}
+
+		rule := Rule{From: fields[0], Status: http.StatusMovedPermanently}
+
+		// The target always starts with a / or a scheme, the query conditions never do
+		rest := fields[1:]
+		for len(rest) > 0 && isQueryCondition(rest[0]) {
+			rule.Query = append(rule.Query, rest[0])
+			rest = rest[1:]
+		}
+		if len(rest) == 0 {
+			return nil, fmt.Errorf("invalid redirect on line %d: %s", line, text)
+		}
+		rule.To = rest[0]
+
+		if len(rest) > 1 {
+			status, force := strings.CutSuffix(rest[1], "!")
+			code, err := strconv.Atoi(status)
+			if err != nil {
+				return nil, fmt.Errorf("invalid redirect status on line %d: %s", line, rest[1])
+			}
+			rule.Status = code
+			rule.Force = force
+		}
+
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading redirects: %v", err)
+	}
+
+	return rules, nil
+}
+
+// isQueryCondition reports whether the field of a rule is a key=value query condition
+func isQueryCondition(field string) bool {
+	return strings.Contains(field, "=") && !strings.HasPrefix(field, "/") && !strings.Contains(field, "://")
+}
+
+// Load reads the rules of a _redirects file, a missing file has no rules
+func Load(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading redirects: %v", err)
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Match returns the first rule matching the path and query and its target,
+// with the placeholders and the splat filled in
+func Match(rules []Rule, path string, query url.Values) (Rule, string, bool) {
+	for _, rule := range rules {
+		if target, ok := rule.Match(path, query); ok {
+			return rule, target, true
+		}
+	}
+	return Rule{}, "", false
+}
+
+// Match returns the target of the rule for the path and query, if it matches.
+// Trailing slashes are ignored, like Netlify does
+func (r Rule) Match(path string, query url.Values) (string, bool) {
+	from := splitPath(r.From)
+	segments := splitPath(path)
+	values := make(map[string]string)
+
+	for _, condition := range r.Query {
+		key, value, _ := strings.Cut(condition, "=")
+		if !query.Has(key) {
+			return "", false
+		}
+		if name, ok := strings.CutPrefix(value, ":"); ok && name != "" {
+			values[name] = query.Get(key)
+			continue
+		}
+		if query.Get(key) != value {
+			return "", false
+		}
+	}
+
+	for i, part := range from {
+		if part == "*" && i == len(from)-1 {
+			values["splat"] = strings.Join(segments[i:], "/")
+			return fill(r.To, values), true
+		}
+		if i >= len(segments) {
+			return "", false
+		}
+		if name, ok := strings.CutPrefix(part, ":"); ok && name != "" {
+			values[name] = segments[i]
+			continue
+		}
+		if part != segments[i] {
+			return "", false
+		}
+	}
+
+	if len(from) != len(segments) {
+		return "", false
+	}
+
+	return fill(r.To, values), true
+}
+
+// splitPath returns the segments of the path, without empty ones
+func splitPath(path string) []string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// fill replaces the :placeholders of the target, longest names first
+// so :splat is not mistaken for a shorter placeholder
+func fill(to string, values map[string]string) string {
+	for len(values) > 0 {
+		longest := ""
+		for name := range values {
+			if len(name) > len(longest) {
+				longest = name
+			}
+		}
+		to = strings.ReplaceAll(to, ":"+longest, values[longest])
+		delete(values, longest)
+	}
+	return to
+}
@@ -0,0 +1,236 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Result is the outcome of a crawled URL
+type Result struct {
+	URL      string   `json:"url"`
+	Status   int      `json:"status,omitempty"`
+	Error    string   `json:"error,omitempty"`
+	Referers []string `json:"referers,omitempty"`
+}
+
+// Broken reports whether the URL did not return a 2xx response,
+// a 304 response of an incremental run keeps the previous file
+func (r Result) Broken() bool {
+	if r.Status == http.StatusNotModified {
+		return false
+	}
+	return r.Status < 200 || r.Status > 299
+}
+
+// Report collects the result of every crawled URL and the pages that link to it
+type Report struct {
+	mu       sync.Mutex
+	results  map[string]Result
+	referers map[string]map[string]bool
+}
+
+// Summary is the machine readable form of the report
+type Summary struct {
+	Checked int      `json:"checked"`
+	Broken  int      `json:"broken"`
+	Results []Result `json:"results"`
+}
+
+// New creates an empty Report
+func New() *Report {
+	return &Report{
+		results:  make(map[string]Result),
+		referers: make(map[string]map[string]bool),
+	}
+}
+
+// AddReferer records that the page referer links to the URL
+func (r *Report) AddReferer(url string, referer string) {
+	if referer == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.referers[url] == nil {
+		r.referers[url] = make(map[string]bool)
+	}
+	r.referers[url][referer] = true
+}
+
+// Add records the final result of a URL, err is nil when there was a response
+func (r *Report) Add(url string, status int, err error) {
+	result := Result{URL: url, Status: status}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results[url] = result
+}
+
+// Result returns the result of the URL with its referers, if it was crawled
+func (r *Report) Result(url string) (Result, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result, ok := r.results[url]
+	if !ok {
+		result = Result{URL: url}
+	}
+	for referer := range r.referers[url] {
+		result.Referers = append(result.Referers, referer)
+	}
+	sort.Strings(result.Referers)
+
+	return result, ok
+}
+
+// Results returns every result with its referers, sorted by URL
+func (r *Report) Results() []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := make([]Result, 0, len(r.results))
+	for url, result := range r.results {
+		for referer := range r.referers[url] {
+			result.Referers = append(result.Referers, referer)
+		}
+		sort.Strings(result.Referers)
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].URL < results[j].URL
+	})
+
+	return results
+}
+
+// Broken returns the results that did not return a 2xx response, sorted by URL
+func (r *Report) Broken() []Result {
+	var broken []Result
+	for _, result := range r.Results() {
+		if result.Broken() {
+			broken = append(broken, result)
+		}
+	}
+	return broken
+}
+
+// Summary returns the counts and the broken results
+func (r *Report) Summary() Summary {
+	results := r.Results()
+	summary := Summary{Checked: len(results), Results: []Result{}}
+	for _, result := range results {
+		if result.Broken() {
+			summary.Broken++
+			summary.Results = append(summary.Results, result)
+		}
+	}
+	return summary
+}
+
+// Check returns an error when the broken URLs exceed the thresholds: any 5xx
+// response when fail5xx is set, or more than maxBroken broken URLs, -1 is no limit
+func (r *Report) Check(fail5xx bool, maxBroken int) error {
+	broken := r.Broken()
+
+	if fail5xx {
+		for _, result := range broken {
+			if result.Status >= 500 {
+				return fmt.Errorf("%s returned status %d", result.URL, result.Status)
+			}
+		}
+	}
+
+	if maxBroken >= 0 && len(broken) > maxBroken {
+		return fmt.Errorf("%d broken URLs, the limit is %d", len(broken), maxBroken)
+	}
+
+	return nil
+}
+
+// SaveJSON writes the summary as JSON
+func (r *Report) SaveJSON(path string) error {
+	data, err := json.MarshalIndent(r.Summary(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding report: %v", err)
+	}
+	return writeFile(path, data)
+}
+
+// junitTestSuite is the <testsuite> element of a JUnit report
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is a <testcase> of a JUnit report, one per crawled URL
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+// junitFailure describes why a URL is broken and which pages link to it
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// SaveJUnit writes the results as a JUnit XML test suite, so CI systems can display them
+func (r *Report) SaveJUnit(path string) error {
+	suite := junitTestSuite{Name: "crawl"}
+	for _, result := range r.Results() {
+		testCase := junitTestCase{ClassName: "crawl", Name: result.URL}
+		if result.Broken() {
+			suite.Failures++
+			testCase.Failure = &junitFailure{
+				Message: result.message(),
+				Text:    "Referenced by:\n" + strings.Join(result.Referers, "\n"),
+			}
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	data, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding report: %v", err)
+	}
+	return writeFile(path, append([]byte(xml.Header), data...))
+}
+
+// message describes the status or error of the result
+func (r Result) message() string {
+	if r.Error != "" && r.Status == 0 {
+		return r.Error
+	}
+	return fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status))
+}
+
+// writeFile writes the data to path, creating its directory
+func writeFile(path string, data []byte) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error creating directory: %v", err)
+		}
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error saving report: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package retry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"wp-go-static/internal/config"
+)
+
+// FileName is the name of the failed URL report stored in the state directory
+const FileName = ".wp-go-static-failed.json"
+
+// Network errors that can be retried
+const (
+	ErrorTimeout = "timeout"
+	ErrorReset   = "reset"
+	ErrorRefused = "refused"
+	ErrorEOF     = "eof"
+	ErrorDNS     = "dns"
+)
+
+// Policy decides whether a failed request is tried again and when
+type Policy struct {
+	attempts int
+	delay    time.Duration
+	maxDelay time.Duration
+	statuses map[int]bool
+	errors   map[string]bool
+}
+
+// New creates a Policy from the scrape configuration
+func New(cfg config.ScrapeConfig) (*Policy, error) {
+	if cfg.Retries < 0 {
+		return nil, fmt.Errorf("retries must not be negative")
+	}
+
+	p := &Policy{
+		attempts: cfg.Retries,
+		delay:    cfg.RetryDelay,
+		maxDelay: cfg.RetryMaxDelay,
+		statuses: make(map[int]bool),
+		errors:   make(map[string]bool),
+	}
+
+	for _, status := range cfg.RetryStatus {
+		if status < 100 || status > 599 {
+			return nil, fmt.Errorf("invalid retry status: %d", status)
+		}
+		p.statuses[status] = true
+	}
+
+	for _, name := range cfg.RetryErrors {
+		switch name {
+		case ErrorTimeout, ErrorReset, ErrorRefused, ErrorEOF, ErrorDNS:
+			p.errors[name] = true
+		default:
+			return nil, fmt.Errorf("unknown retry error: %s", name)
+		}
+	}
+
+	return p, nil
+}
+
+// Retryable reports whether a request that failed with the status code,
+// or with err when there was no response, may succeed when tried again
+func (p *Policy) Retryable(statusCode int, err error) bool {
+	if statusCode != 0 {
+		return p.statuses[statusCode]
+	}
+	return err != nil && p.errors[errorKind(err)]
+}
+
+// Attempts returns how many times a failed request is tried again
+func (p *Policy) Attempts() int {
+	return p.attempts
+}
+
+// Delay returns how long to wait before the given retry, starting at 1.
+// The delay doubles with every attempt, up to the maximum delay
+func (p *Policy) Delay(attempt int) time.Duration {
+	delay := p.delay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.maxDelay > 0 && delay >= p.maxDelay {
+			break
+		}
+	}
+	if p.maxDelay > 0 && delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+	return delay
+}
+
+// errorKind returns the name of the network error, or an empty string
+func errorKind(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return ErrorDNS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case errors.Is(err, syscall.ECONNRESET):
+		return ErrorReset
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorRefused
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorEOF
+	}
+	return ""
+}
+
+// Failure is a URL that could not be downloaded after every retry
+type Failure struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status,omitempty"`
+	Error    string `json:"error"`
+	Attempts int    `json:"attempts"`
+}
+
+// Report collects the URLs that failed during a run
+type Report struct {
+	mu       sync.Mutex
+	dir      string
+	failures map[string]Failure
+}
+
+// NewReport creates an empty report saved to the state directory
+func NewReport(dir string) *Report {
+	return &Report{
+		dir:      dir,
+		failures: make(map[string]Failure),
+	}
+}
+
+// Add records a failed URL, replacing an earlier failure of the same URL
+func (r *Report) Add(failure Failure) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[failure.URL] = failure
+}
+
+// Failures returns the failed URLs sorted by URL
+func (r *Report) Failures() []Failure {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failures := make([]Failure, 0, len(r.failures))
+	for _, failure := range r.failures {
+		failures = append(failures, failure)
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].URL < failures[j].URL
+	})
+
+	return failures
+}
+
+// Save writes the report to the state directory, or removes
+// the report of a previous run when nothing failed
+func (r *Report) Save() error {
+	path := filepath.Join(r.dir, FileName)
+
+	failures := r.Failures()
+	if len(failures) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing failed URL report: %v", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(failures, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding failed URL report: %v", err)
+	}
+
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error saving failed URL report: %v", err)
+	}
+
+	return nil
+}
+
+// LoadFailures reads the failed URLs reported by the previous run in the state directory
+func LoadFailures(dir string) ([]Failure, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading failed URL report: %v", err)
+	}
+
+	var failures []Failure
+	if err := json.Unmarshal(data, &failures); err != nil {
+		return nil, fmt.Errorf("error parsing failed URL report: %v", err)
+	}
+
+	return failures, nil
+}
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"wp-go-static/internal/redirects"
+)
+
+// liveReloadPath is the event stream the injected script listens to
+const liveReloadPath = "/__live-reload"
+
+// liveReloadScript reloads the page when the server reports a change
+const liveReloadScript = `<script>new EventSource("` + liveReloadPath + `").onmessage = function () { location.reload(); };</script>`
+
+// extraTypes are MIME types missing from some system tables
+var extraTypes = map[string]string{
+	".avif":  "image/avif",
+	".ico":   "image/x-icon",
+	".mjs":   "text/javascript; charset=utf-8",
+	".mp4":   "video/mp4",
+	".otf":   "font/otf",
+	".ttf":   "font/ttf",
+	".webm":  "video/webm",
+	".webp":  "image/webp",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+}
+
+func init() {
+	for ext, mediaType := range extraTypes {
+		if mime.TypeByExtension(ext) == "" {
+			mime.AddExtensionType(ext, mediaType)
+		}
+	}
+}
+
+// Server serves an exported site the way a static host does: directory indexes,
+// _redirects rules and a 404.html page
+type Server struct {
+	dir        string
+	liveReload bool
+
+	mu       sync.Mutex
+	changed  chan struct{}
+	snapshot string
+}
+
+// New creates a Server for the directory. With live reload enabled the HTML
+// pages reload themselves when a file of the directory changes
+func New(dir string, liveReload bool) *Server {
+	return &Server{
+		dir:        dir,
+		liveReload: liveReload,
+		changed:    make(chan struct{}),
+	}
+}
+
+// Watch polls the directory for changes until stop is closed
+func (s *Server) Watch(interval time.Duration, stop <-chan struct{}) {
+	s.snapshot = s.scan()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			snapshot := s.scan()
+			if snapshot == s.snapshot {
+				continue
+			}
+			s.snapshot = snapshot
+
+			log.Println("Files changed, reloading")
+			s.mu.Lock()
+			close(s.changed)
+			s.changed = make(chan struct{})
+			s.mu.Unlock()
+		}
+	}
+}
+
+// scan returns a fingerprint of the names, sizes and modification times of the files
+func (s *Server) scan() string {
+	var buf bytes.Buffer
+	filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(&buf, "%s %d %d\n", p, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return buf.String()
+}
+
+// ServeHTTP serves a file of the directory
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.liveReload && r.URL.Path == liveReloadPath {
+		s.events(w, r)
+		return
+	}
+
+	urlPath := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") && urlPath != "/" {
+		urlPath += "/"
+	}
+
+	rules, err := redirects.Load(filepath.Join(s.dir, redirects.FileName))
+	if err != nil {
+		log.Println(err)
+	}
+
+	file, isDir := s.resolve(urlPath)
+
+	// Like Netlify, existing files shadow the rules that are not forced
+	if rule, target, ok := redirects.Match(rules, urlPath, r.URL.Query()); ok && (file == "" || rule.Force) {
+		if rule.Status != http.StatusOK {
+			log.Printf("%d %s -> %s\n", rule.Status, r.URL.Path, target)
+			http.Redirect(w, r, target, rule.Status)
+			return
+		}
+		urlPath = target
+		file, isDir = s.resolve(target)
+	}
+
+	if file == "" {
+		s.notFound(w, r)
+		return
+	}
+
+	// Directories are only served with a trailing slash, so relative links work
+	if isDir && !strings.HasSuffix(urlPath, "/") {
+		target := urlPath + "/"
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return
+	}
+
+	s.serveFile(w, r, file, http.StatusOK)
+}
+
+// resolve returns the file of the URL path, the index.html of directories,
+// or an empty string when there is none
+func (s *Server) resolve(urlPath string) (string, bool) {
+	if i := strings.IndexAny(urlPath, "?#"); i >= 0 {
+		urlPath = urlPath[:i]
+	}
+
+	file := filepath.Join(s.dir, filepath.FromSlash(path.Clean("/"+urlPath)))
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", false
+	}
+
+	if !info.IsDir() {
+		return file, false
+	}
+
+	index := filepath.Join(file, "index.html")
+	if info, err := os.Stat(index); err == nil && !info.IsDir() {
+		return index, true
+	}
+
+	return "", false
+}
+
+// notFound serves the 404.html page of the site, or a plain 404
+func (s *Server) notFound(w http.ResponseWriter, r *http.Request) {
+	log.Printf("404 %s\n", r.URL.Path)
+
+	page := filepath.Join(s.dir, "404.html")
+	if _, err := os.Stat(page); err == nil {
+		s.serveFile(w, r, page, http.StatusNotFound)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// serveFile writes the file with the MIME type of its extension,
+// HTML pages get the live reload script when it is enabled
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, file string, status int) {
+	ext := strings.ToLower(filepath.Ext(file))
+	isHTML := ext == ".html" || ext == ".htm"
+
+	if status == http.StatusOK && !(s.liveReload && isHTML) {
+		// ServeContent handles ranges and conditional requests
+		f, err := os.Open(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+		return
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if s.liveReload && isHTML {
+		data = injectScript(data)
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
+	mediaType := mime.TypeByExtension(ext)
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// injectScript adds the live reload script before the closing body tag, or at the end
+func injectScript(data []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(data), []byte("</body>"))
+	if i < 0 {
+		return append(data, liveReloadScript...)
+	}
+
+	injected := make([]byte, 0, len(data)+len(liveReloadScript))
+	injected = append(injected, data[:i]...)
+	injected = append(injected, liveReloadScript...)
+	return append(injected, data[i:]...)
+}
+
+// events streams a message every time the directory changes
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	flusher.Flush()
+
+	for {
+		s.mu.Lock()
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changed:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package throttle
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minBackoff is the first pause after the origin asks to slow down
+const minBackoff = time.Second
+
+// Throttle limits the request rate and pauses every request
+// when the origin answers that it is overloaded
+type Throttle struct {
+	mu         sync.Mutex
+	interval   time.Duration
+	next       time.Time
+	paused     time.Time
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// New creates a Throttle that sends at most maxRPS requests per second, 0 is unlimited,
+// and never pauses for longer than maxBackoff
+func New(maxRPS float64, maxBackoff time.Duration) *Throttle {
+	t := &Throttle{maxBackoff: maxBackoff}
+	if maxRPS > 0 {
+		t.interval = time.Duration(float64(time.Second) / maxRPS)
+	}
+	return t
+}
+
+// Wait blocks until the next request may be sent
+func (t *Throttle) Wait() {
+	for {
+		t.mu.Lock()
+		now := time.Now()
+		wait := t.next.Sub(now)
+		if pause := t.paused.Sub(now); pause > wait {
+			wait = pause
+		}
+		if wait <= 0 {
+			t.next = now.Add(t.interval)
+			t.mu.Unlock()
+			return
+		}
+		t.mu.Unlock()
+
+		time.Sleep(wait)
+	}
+}
+
+// Backoff pauses every request and returns for how long. The pause doubles each time
+// the origin asks to slow down, unless it sent how long to wait in retryAfter
+func (t *Throttle) Backoff(retryAfter time.Duration) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.backoff == 0 {
+		t.backoff = minBackoff
+	} else {
+		t.backoff *= 2
+	}
+	if t.maxBackoff > 0 && t.backoff > t.maxBackoff {
+		t.backoff = t.maxBackoff
+	}
+
+	pause := t.backoff
+	if retryAfter > 0 {
+		pause = retryAfter
+		if t.maxBackoff > 0 && pause > t.maxBackoff {
+			pause = t.maxBackoff
+		}
+	}
+
+	if until := time.Now().Add(pause); until.After(t.paused) {
+		t.paused = until
+	}
+
+	return pause
+}
+
+// Success halves the backoff after a successful response, so the rate recovers gradually
+func (t *Throttle) Success() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.backoff /= 2
+	if t.backoff < minBackoff {
+		t.backoff = 0
+	}
+}
+
+// IsOverloaded reports whether the status code asks the client to slow down
+func IsOverloaded(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+}
+
+// ParseRetryAfter parses a Retry-After header, either a number of seconds or an HTTP date.
+// It returns 0 when the header is missing or invalid
+func ParseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
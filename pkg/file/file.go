@@ -25,8 +25,10 @@ func NewCollector() *colly.Collector {
 	return c
 }
 
-// HandleFile handles the file and returns the directory and file name
-func HandleFile(r *colly.Response, filePath string) (string, string) {
+// HandleFile handles the file and returns the directory and file name.
+// When encodeQuery is set the query string of the URL is part of the file name,
+// otherwise it is dropped
+func HandleFile(r *colly.Response, filePath string, encodeQuery bool) (string, string) {
 	baseDir, fileName, err := url.ParsePath(r.Request.URL.String())
 	if err != nil {
 		fmt.Println(err)
@@ -43,7 +45,11 @@ func HandleFile(r *colly.Response, filePath string) (string, string) {
 		fileName = "index.html"
 	}
 
-	dir := filepath.Join(".", filePath, baseDir)
+	if encodeQuery {
+		fileName = url.QueryFileName(fileName, r.Request.URL.RawQuery)
+	}
+
+	dir := filepath.Join(filePath, baseDir)
 	err = createDirectory(dir)
 	if err != nil {
 		fmt.Println(err)
@@ -89,6 +95,13 @@ func GetExtension(fileName string, contentType string) (string, error) {
 
 		ext = metaExtension[0]
 
+		// The list is sorted, so .ehtml or .htm may come before .html
+		for _, e := range metaExtension {
+			if e == ".html" {
+				ext = e
+			}
+		}
+
 		if ext == ".htm" {
 			ext = ".html"
 		}
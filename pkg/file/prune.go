@@ -0,0 +1,113 @@
+package file
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Orphans walks the directory and returns the files that are not in the produced set.
+// Files matching one of the keep patterns and everything inside the skip directories are ignored
+func Orphans(dir string, produced map[string]bool, keep []string, skipDirs []string) ([]string, error) {
+	var orphans []string
+
+	skip := make(map[string]bool)
+	for _, skipDir := range skipDirs {
+		if skipDir != "" {
+			skip[filepath.Clean(skipDir)] = true
+		}
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if skip[filepath.Clean(path)] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if produced[filepath.Clean(path)] {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		for _, pattern := range keep {
+			if ok, _ := filepath.Match(pattern, filepath.ToSlash(rel)); ok {
+				return nil
+			}
+		}
+
+		orphans = append(orphans, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking directory: %v", err)
+	}
+
+	return orphans, nil
+}
+
+// RemoveFiles deletes the files, or moves them into the quarantine directory
+// keeping their path relative to dir when quarantine is set
+func RemoveFiles(dir string, files []string, quarantine string) error {
+	for _, path := range files {
+		if quarantine == "" {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("error removing file: %v", err)
+			}
+			continue
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return fmt.Errorf("error moving file: %v", err)
+		}
+
+		target := filepath.Join(quarantine, rel)
+		if err := createDirectory(filepath.Dir(target)); err != nil {
+			return err
+		}
+
+		if err := os.Rename(path, target); err != nil {
+			return fmt.Errorf("error moving file: %v", err)
+		}
+	}
+
+	return removeEmptyDirectories(dir)
+}
+
+// removeEmptyDirectories removes the directories left empty after pruning
+func removeEmptyDirectories(dir string) error {
+	var dirs []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != dir {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking directory: %v", err)
+	}
+
+	// Deepest directories first, so parents can become empty too
+	for i := len(dirs) - 1; i >= 0; i-- {
+		entries, err := os.ReadDir(dirs[i])
+		if err == nil && len(entries) == 0 {
+			os.Remove(dirs[i])
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package robots
+
+import (
+	"strings"
+)
+
+// Allowed reports whether the user agent may crawl the path, which includes
+// the query string. The groups of the user agent are used, or else the ones
+// of *, and the longest matching rule wins, Allow winning ties (RFC 9309)
+func (rb *Robots) Allowed(agent string, path string) bool {
+	rules := rb.rules(agent)
+	if rules == nil {
+		rules = rb.rules("*")
+	}
+
+	allowed := true
+	longest := -1
+	for _, rule := range rules {
+		// An empty Disallow matches nothing
+		if rule.Path == "" {
+			continue
+		}
+		if !match(rule.Path, path) {
+			continue
+		}
+		if len(rule.Path) > longest || (len(rule.Path) == longest && rule.Allow) {
+			longest = len(rule.Path)
+			allowed = rule.Allow
+		}
+	}
+
+	return allowed
+}
+
+// rules returns the rules of every group listing the user agent, nil when there is none
+func (rb *Robots) rules(agent string) []Rule {
+	rules := []Rule{}
+	found := false
+	for _, group := range rb.Groups {
+		for _, a := range group.UserAgents {
+			if strings.EqualFold(a, agent) {
+				found = true
+				rules = append(rules, group.Rules...)
+				break
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	return rules
+}
+
+// match reports whether the path starts with the pattern, where * matches
+// any sequence of characters and a trailing $ anchors the end of the path
+func match(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+
+	for i, part := range parts[1:] {
+		// The last part of an anchored pattern must end the path
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		j := strings.Index(rest, part)
+		if j < 0 {
+			return false
+		}
+		rest = rest[j+len(part):]
+	}
+
+	return !anchored || rest == ""
+}
+
+// indexingRules are the rules of meta robots and X-Robots-Tag whose
+// value follows a colon, so they are not mistaken for a user agent
+var indexingRules = map[string]bool{
+	"unavailable_after": true,
+	"max-snippet":       true,
+	"max-image-preview": true,
+	"max-video-preview": true,
+}
+
+// NoIndex reports whether any of the values of a meta robots tag or
+// X-Robots-Tag header asks every crawler not to index the page. Values
+// addressed to a single crawler, like "googlebot: noindex", are ignored
+func NoIndex(values ...string) bool {
+	for _, value := range values {
+		if agent, rest, ok := strings.Cut(value, ":"); ok {
+			name := strings.ToLower(strings.TrimSpace(agent))
+			if !strings.Contains(name, ",") && !indexingRules[name] {
+				if name != "*" {
+					continue
+				}
+				value = rest
+			}
+		}
+
+		for _, token := range strings.Split(value, ",") {
+			token = strings.ToLower(strings.TrimSpace(token))
+			if token == "noindex" || token == "none" {
+				return true
+			}
+		}
+	}
+	return false
+}
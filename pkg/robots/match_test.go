@@ -0,0 +1,68 @@
+package robots
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	const wordpress = "User-agent: *\nDisallow: /wp-admin/\nAllow: /wp-admin/admin-ajax.php\nDisallow: /*?s=\nDisallow: /*.pdf$\n\nUser-agent: GPTBot\nDisallow: /\n"
+
+	tests := []struct {
+		name   string
+		robots string
+		agent  string
+		path   string
+		want   bool
+	}{
+		{name: "no rules", robots: "", agent: "bot", path: "/", want: true},
+		{name: "not matched", robots: wordpress, agent: "bot", path: "/about/", want: true},
+		{name: "disallowed prefix", robots: wordpress, agent: "bot", path: "/wp-admin/options.php", want: false},
+		{name: "longer allow wins", robots: wordpress, agent: "bot", path: "/wp-admin/admin-ajax.php", want: true},
+		{name: "wildcard", robots: wordpress, agent: "bot", path: "/blog/?s=term", want: false},
+		{name: "anchored", robots: wordpress, agent: "bot", path: "/files/a.pdf", want: false},
+		{name: "anchored not at the end", robots: wordpress, agent: "bot", path: "/files/a.pdf?x=1", want: true},
+		{name: "own group", robots: wordpress, agent: "GPTBot", path: "/about/", want: false},
+		{name: "own group case insensitive", robots: wordpress, agent: "gptbot", path: "/about/", want: false},
+		{name: "own group replaces *", robots: wordpress, agent: "GPTBot", path: "/wp-admin/admin-ajax.php", want: false},
+		{name: "allow wins ties", robots: "User-agent: *\nDisallow: /a\nAllow: /a\n", agent: "bot", path: "/a", want: true},
+		{name: "empty disallow", robots: "User-agent: *\nDisallow:\n", agent: "bot", path: "/", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rb, err := Parse(strings.NewReader(tt.robots))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rb.Allowed(tt.agent, tt.path); got != tt.want {
+				t.Errorf("Allowed(%q, %q) = %v, want %v", tt.agent, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoIndex(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   bool
+	}{
+		{values: nil, want: false},
+		{values: []string{"index, follow"}, want: false},
+		{values: []string{"noindex"}, want: true},
+		{values: []string{"NoIndex, nofollow"}, want: true},
+		{values: []string{"none"}, want: true},
+		{values: []string{"googlebot: noindex"}, want: false},
+		{values: []string{"*: noindex"}, want: true},
+		{values: []string{"max-snippet: -1, noindex"}, want: true},
+		{values: []string{"follow", "noindex"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.values, "|"), func(t *testing.T) {
+			if got := NoIndex(tt.values...); got != tt.want {
+				t.Errorf("NoIndex(%q) = %v, want %v", tt.values, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package robots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Rule is an Allow or Disallow line of a group
+type Rule struct {
+	Allow bool
+	Path  string
+}
+
+// Directive is any other line of a group, like Crawl-delay
+type Directive struct {
+	Key   string
+	Value string
+}
+
+// Group lists the rules that apply to some user agents
+type Group struct {
+	UserAgents []string
+	Rules      []Rule
+	Other      []Directive
+}
+
+// Robots is a parsed robots.txt
+type Robots struct {
+	Groups   []Group
+	Sitemaps []string
+}
+
+// Parse reads a robots.txt. Comments are dropped and lines outside of
+// a group, other than Sitemap, are ignored
+func Parse(r io.Reader) (*Robots, error) {
+	rb := &Robots{}
+	var group *Group
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive user agents share a group
+			if group == nil || len(group.Rules) > 0 || len(group.Other) > 0 {
+				rb.Groups = append(rb.Groups, Group{})
+				group = &rb.Groups[len(rb.Groups)-1]
+			}
+			group.UserAgents = append(group.UserAgents, value)
+		case "sitemap":
+			if value != "" {
+				rb.Sitemaps = append(rb.Sitemaps, value)
+			}
+		case "allow", "disallow":
+			if group == nil {
+				continue
+			}
+			// An empty Disallow allows everything and is kept as is
+			if value == "" && key == "allow" {
+				continue
+			}
+			group.Rules = append(group.Rules, Rule{Allow: key == "allow", Path: value})
+		default:
+			if group == nil {
+				continue
+			}
+			group.Other = append(group.Other, Directive{Key: canonicalKey(key), Value: value})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading robots.txt: %v", err)
+	}
+
+	return rb, nil
+}
+
+// canonicalKey capitalizes the words of a directive, crawl-delay becomes Crawl-delay
+func canonicalKey(key string) string {
+	if key == "" {
+		return key
+	}
+	return strings.ToUpper(key[:1]) + key[1:]
+}
+
+// String writes the robots.txt
+func (rb *Robots) String() string {
+	var b strings.Builder
+
+	for i, group := range rb.Groups {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		for _, agent := range group.UserAgents {
+			fmt.Fprintf(&b, "User-agent: %s\n", agent)
+		}
+		for _, rule := range group.Rules {
+			if rule.Allow {
+				fmt.Fprintf(&b, "Allow: %s\n", rule.Path)
+			} else {
+				fmt.Fprintf(&b, "Disallow: %s\n", rule.Path)
+			}
+		}
+		for _, d := range group.Other {
+			fmt.Fprintf(&b, "%s: %s\n", d.Key, d.Value)
+		}
+	}
+
+	if len(rb.Sitemaps) > 0 && len(rb.Groups) > 0 {
+		b.WriteString("\n")
+	}
+	for _, loc := range rb.Sitemaps {
+		fmt.Fprintf(&b, "Sitemap: %s\n", loc)
+	}
+
+	return b.String()
+}
+
+// Group returns the group listing the user agent, compared case-insensitively
+func (rb *Robots) Group(agent string) *Group {
+	for i := range rb.Groups {
+		for _, a := range rb.Groups[i].UserAgents {
+			if strings.EqualFold(a, agent) {
+				return &rb.Groups[i]
+			}
+		}
+	}
+	return nil
+}
+
+// Merge adds the rules and directives of other to the groups of the same
+// user agents, groups of new user agents are appended
+func (rb *Robots) Merge(other *Robots) {
+	for _, og := range other.Groups {
+		for _, agent := range og.UserAgents {
+			group := rb.Group(agent)
+			if group == nil {
+				rb.Groups = append(rb.Groups, Group{UserAgents: []string{agent}})
+				group = &rb.Groups[len(rb.Groups)-1]
+			}
+			group.add(og)
+		}
+	}
+
+	for _, loc := range other.Sitemaps {
+		rb.AddSitemap(loc)
+	}
+}
+
+// Override replaces the groups of the user agents listed by other with
+// the groups of other
+func (rb *Robots) Override(other *Robots) {
+	for _, og := range other.Groups {
+		for _, agent := range og.UserAgents {
+			rb.remove(agent)
+		}
+	}
+
+	for _, og := range other.Groups {
+		group := Group{UserAgents: append([]string{}, og.UserAgents...)}
+		group.add(og)
+		rb.Groups = append(rb.Groups, group)
+	}
+
+	for _, loc := range other.Sitemaps {
+		rb.AddSitemap(loc)
+	}
+}
+
+// remove drops the user agent from its group, and the group when it was the only one
+func (rb *Robots) remove(agent string) {
+	groups := rb.Groups[:0]
+	for _, group := range rb.Groups {
+		agents := group.UserAgents[:0]
+		for _, a := range group.UserAgents {
+			if !strings.EqualFold(a, agent) {
+				agents = append(agents, a)
+			}
+		}
+		group.UserAgents = agents
+		if len(agents) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	rb.Groups = groups
+}
+
+// add appends the rules and directives of other missing from the group
+func (g *Group) add(other Group) {
+	for _, rule := range other.Rules {
+		if !g.hasRule(rule) {
+			g.Rules = append(g.Rules, rule)
+		}
+	}
+	for _, d := range other.Other {
+		if !g.hasDirective(d) {
+			g.Other = append(g.Other, d)
+		}
+	}
+}
+
+func (g *Group) hasRule(rule Rule) bool {
+	for _, r := range g.Rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Group) hasDirective(d Directive) bool {
+	for _, o := range g.Other {
+		if strings.EqualFold(o.Key, d.Key) && o.Value == d.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// AddSitemap lists a sitemap unless it already is
+func (rb *Robots) AddSitemap(loc string) {
+	for _, s := range rb.Sitemaps {
+		if s == loc {
+			return
+		}
+	}
+	rb.Sitemaps = append(rb.Sitemaps, loc)
+}
+
+// RewriteURLs replaces the URLs of the Sitemap lines and of the other
+// directives, like Host, with rewrite(URL)
+func (rb *Robots) RewriteURLs(rewrite func(string) string) {
+	for i := range rb.Sitemaps {
+		rb.Sitemaps[i] = rewrite(rb.Sitemaps[i])
+	}
+	for i := range rb.Groups {
+		for j := range rb.Groups[i].Other {
+			rb.Groups[i].Other[j].Value = rewrite(rb.Groups[i].Other[j].Value)
+		}
+	}
+}
+
+// DisallowsAll returns the user agents that may not crawl the root of the
+// site, following the same longest match rule as Allowed, so an Allow of a
+// single file, like /wp-admin/admin-ajax.php, does not let a Disallow: / pass
+func (rb *Robots) DisallowsAll() []string {
+	var agents []string
+	seen := make(map[string]bool)
+	for _, group := range rb.Groups {
+		for _, agent := range group.UserAgents {
+			key := strings.ToLower(agent)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if !rb.Allowed(agent, "/") {
+				agents = append(agents, agent)
+			}
+		}
+	}
+	return agents
+}
@@ -0,0 +1,209 @@
+package robots
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDisallowsAll(t *testing.T) {
+	tests := []struct {
+		name   string
+		robots string
+		want   []string
+	}{
+		{
+			name:   "empty",
+			robots: "",
+			want:   nil,
+		},
+		{
+			name:   "disallow root",
+			robots: "User-agent: *\nDisallow: /\n",
+			want:   []string{"*"},
+		},
+		{
+			name:   "disallow wildcard",
+			robots: "User-agent: *\nDisallow: /*\n",
+			want:   []string{"*"},
+		},
+		{
+			name:   "disallow root with a single file allowed back",
+			robots: "User-agent: *\nDisallow: /\nAllow: /wp-admin/admin-ajax.php\n",
+			want:   []string{"*"},
+		},
+		{
+			name:   "allow before disallow root",
+			robots: "User-agent: *\nAllow: /wp-admin/admin-ajax.php\nDisallow: /\n",
+			want:   []string{"*"},
+		},
+		{
+			name:   "allow root back",
+			robots: "User-agent: *\nDisallow: /\nAllow: /\n",
+			want:   nil,
+		},
+		{
+			name:   "allow root back anchored",
+			robots: "User-agent: *\nDisallow: /\nAllow: /$\n",
+			want:   nil,
+		},
+		{
+			name:   "wordpress default",
+			robots: "User-agent: *\nDisallow: /wp-admin/\nAllow: /wp-admin/admin-ajax.php\n",
+			want:   nil,
+		},
+		{
+			name:   "empty disallow",
+			robots: "User-agent: *\nDisallow:\n",
+			want:   nil,
+		},
+		{
+			name:   "single crawler",
+			robots: "User-agent: *\nDisallow: /wp-admin/\n\nUser-agent: GPTBot\nUser-agent: CCBot\nDisallow: /\n",
+			want:   []string{"GPTBot", "CCBot"},
+		},
+		{
+			name:   "groups of the same agent are combined",
+			robots: "User-agent: Googlebot\nDisallow: /\n\nUser-agent: googlebot\nAllow: /\n",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rb, err := Parse(strings.NewReader(tt.robots))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rb.DisallowsAll(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DisallowsAll() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		robots string
+		want   *Robots
+	}{
+		{
+			name:   "empty",
+			robots: "",
+			want:   &Robots{},
+		},
+		{
+			name:   "consecutive user agents share a group",
+			robots: "User-agent: a\nuser-agent: b\nDisallow: /x # private\nCrawl-delay: 5\n\nUser-agent: c\nAllow: /\n",
+			want: &Robots{Groups: []Group{
+				{UserAgents: []string{"a", "b"}, Rules: []Rule{{Path: "/x"}}, Other: []Directive{{Key: "Crawl-delay", Value: "5"}}},
+				{UserAgents: []string{"c"}, Rules: []Rule{{Allow: true, Path: "/"}}},
+			}},
+		},
+		{
+			name:   "rules outside of a group are ignored",
+			robots: "Disallow: /\nHost: example.com\nSitemap: https://example.com/sitemap.xml\n",
+			want:   &Robots{Sitemaps: []string{"https://example.com/sitemap.xml"}},
+		},
+		{
+			name:   "empty allow is dropped, empty disallow is kept",
+			robots: "User-agent: *\nAllow:\nDisallow:\n",
+			want: &Robots{Groups: []Group{
+				{UserAgents: []string{"*"}, Rules: []Rule{{Path: ""}}},
+			}},
+		},
+		{
+			name:   "lines without a colon are ignored",
+			robots: "User-agent: *\nnonsense\nDisallow: /a\n",
+			want: &Robots{Groups: []Group{
+				{UserAgents: []string{"*"}, Rules: []Rule{{Path: "/a"}}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.robots))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	robots := "User-agent: a\nUser-agent: b\nDisallow: /x\nAllow: /x/y\nCrawl-delay: 5\n\nUser-agent: *\nDisallow: /wp-admin/\n\nSitemap: https://example.com/sitemap.xml\n"
+
+	rb, err := Parse(strings.NewReader(robots))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rb.String(); got != robots {
+		t.Errorf("String() = %q, want %q", got, robots)
+	}
+}
+
+func TestMergeOverride(t *testing.T) {
+	const base = "User-agent: *\nDisallow: /wp-admin/\n\nUser-agent: GPTBot\nUser-agent: CCBot\nDisallow: /\n\nSitemap: https://example.com/sitemap.xml\n"
+
+	tests := []struct {
+		name     string
+		other    string
+		override bool
+		want     string
+	}{
+		{
+			name:  "merge adds missing rules",
+			other: "User-agent: *\nDisallow: /wp-admin/\nDisallow: /private/\n",
+			want:  "User-agent: *\nDisallow: /wp-admin/\nDisallow: /private/\n\nUser-agent: GPTBot\nUser-agent: CCBot\nDisallow: /\n\nSitemap: https://example.com/sitemap.xml\n",
+		},
+		{
+			name:  "merge appends new agents",
+			other: "User-agent: Bingbot\nCrawl-delay: 5\n\nSitemap: https://example.com/sitemap.xml\nSitemap: https://example.com/news.xml\n",
+			want:  "User-agent: *\nDisallow: /wp-admin/\n\nUser-agent: GPTBot\nUser-agent: CCBot\nDisallow: /\n\nUser-agent: Bingbot\nCrawl-delay: 5\n\nSitemap: https://example.com/sitemap.xml\nSitemap: https://example.com/news.xml\n",
+		},
+		{
+			name:  "merge into a shared group",
+			other: "User-agent: ccbot\nAllow: /public/\n",
+			want:  "User-agent: *\nDisallow: /wp-admin/\n\nUser-agent: GPTBot\nUser-agent: CCBot\nDisallow: /\nAllow: /public/\n\nSitemap: https://example.com/sitemap.xml\n",
+		},
+		{
+			name:     "override replaces the group",
+			other:    "User-agent: *\nDisallow: /private/\n",
+			override: true,
+			want:     "User-agent: GPTBot\nUser-agent: CCBot\nDisallow: /\n\nUser-agent: *\nDisallow: /private/\n\nSitemap: https://example.com/sitemap.xml\n",
+		},
+		{
+			name:     "override takes an agent out of a shared group",
+			other:    "User-agent: CCBot\nAllow: /\n",
+			override: true,
+			want:     "User-agent: *\nDisallow: /wp-admin/\n\nUser-agent: GPTBot\nDisallow: /\n\nUser-agent: CCBot\nAllow: /\n\nSitemap: https://example.com/sitemap.xml\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rb, err := Parse(strings.NewReader(base))
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := Parse(strings.NewReader(tt.other))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.override {
+				rb.Override(other)
+			} else {
+				rb.Merge(other)
+			}
+			if got := rb.String(); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
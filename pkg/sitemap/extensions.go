@@ -0,0 +1,255 @@
+package sitemap
+
+import (
+	"encoding/xml"
+)
+
+// Namespaces of the sitemap extensions
+const (
+	ImageNamespace = "http://www.google.com/schemas/sitemap-image/1.1"
+	VideoNamespace = "http://www.google.com/schemas/sitemap-video/1.1"
+	NewsNamespace  = "http://www.google.com/schemas/sitemap-news/0.9"
+	XHTMLNamespace = "http://www.w3.org/1999/xhtml"
+	XSINamespace   = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// Image is a structure of <image:image> in <url>
+type Image struct {
+	Loc     string `xml:"loc"`
+	Title   string `xml:"title,omitempty"`
+	Caption string `xml:"caption,omitempty"`
+	GeoLoc  string `xml:"geo_location,omitempty"`
+	License string `xml:"license,omitempty"`
+}
+
+// Video is a structure of <video:video> in <url>
+type Video struct {
+	ThumbnailLoc         string       `xml:"thumbnail_loc"`
+	Title                string       `xml:"title"`
+	Description          string       `xml:"description"`
+	ContentLoc           string       `xml:"content_loc,omitempty"`
+	PlayerLoc            string       `xml:"player_loc,omitempty"`
+	Duration             int          `xml:"duration,omitempty"`
+	ExpirationDate       string       `xml:"expiration_date,omitempty"`
+	Rating               float32      `xml:"rating,omitempty"`
+	ViewCount            int          `xml:"view_count,omitempty"`
+	PublicationDate      string       `xml:"publication_date,omitempty"`
+	FamilyFriendly       string       `xml:"family_friendly,omitempty"`
+	Restriction          *Restriction `xml:"restriction"`
+	Platform             *Restriction `xml:"platform"`
+	RequiresSubscription string       `xml:"requires_subscription,omitempty"`
+	Uploader             *Uploader    `xml:"uploader"`
+	Live                 string       `xml:"live,omitempty"`
+	Tag                  []string     `xml:"tag,omitempty"`
+}
+
+// Restriction is a structure of <video:restriction> and <video:platform> in <video:video>
+type Restriction struct {
+	Relationship string `xml:"relationship,attr"`
+	Value        string `xml:",chardata"`
+}
+
+// Uploader is a structure of <video:uploader> in <video:video>
+type Uploader struct {
+	Info  string `xml:"info,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+// News is a structure of <news:news> in <url>
+type News struct {
+	Publication     Publication `xml:"publication"`
+	PublicationDate string      `xml:"publication_date"`
+	Title           string      `xml:"title"`
+	Keywords        string      `xml:"keywords,omitempty"`
+	StockTickers    string      `xml:"stock_tickers,omitempty"`
+}
+
+// Publication is a structure of <news:publication> in <news:news>
+type Publication struct {
+	Name     string `xml:"name"`
+	Language string `xml:"language"`
+}
+
+// Alternate is a structure of <xhtml:link> in <url>, listing a
+// localized version of the page
+type Alternate struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// RewriteURLs replaces every URL of the sitemap, including the ones of
+// the image, video and news extensions and the alternates, with rewrite(URL)
+func (smap *Sitemap) RewriteURLs(rewrite func(string) string) {
+	for i := range smap.URL {
+		u := &smap.URL[i]
+		u.Loc = rewrite(u.Loc)
+
+		for j := range u.Images {
+			u.Images[j].Loc = rewrite(u.Images[j].Loc)
+			if u.Images[j].License != "" {
+				u.Images[j].License = rewrite(u.Images[j].License)
+			}
+		}
+
+		for j := range u.Videos {
+			v := &u.Videos[j]
+			v.ThumbnailLoc = rewrite(v.ThumbnailLoc)
+			if v.ContentLoc != "" {
+				v.ContentLoc = rewrite(v.ContentLoc)
+			}
+			if v.PlayerLoc != "" {
+				v.PlayerLoc = rewrite(v.PlayerLoc)
+			}
+			if v.Uploader != nil && v.Uploader.Info != "" {
+				v.Uploader.Info = rewrite(v.Uploader.Info)
+			}
+		}
+
+		for j := range u.Alternates {
+			u.Alternates[j].Href = rewrite(u.Alternates[j].Href)
+		}
+	}
+}
+
+// The types below mirror the ones above with prefixed element names, since
+// encoding/xml can only marshal namespaced elements as default namespaces.
+// They are only used to write sitemaps.
+
+// urlsetXML is the marshaled form of Sitemap
+type urlsetXML struct {
+	XMLName        xml.Name `xml:"urlset"`
+	Xmlns          string   `xml:"xmlns,attr"`
+	XmlnsXSI       string   `xml:"xmlns:xsi,attr,omitempty"`
+	XmlnsImage     string   `xml:"xmlns:image,attr,omitempty"`
+	XmlnsVideo     string   `xml:"xmlns:video,attr,omitempty"`
+	XmlnsNews      string   `xml:"xmlns:news,attr,omitempty"`
+	XmlnsXHTML     string   `xml:"xmlns:xhtml,attr,omitempty"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr,omitempty"`
+	URL            []URL    `xml:"url"`
+}
+
+// urlXML is the marshaled form of URL
+type urlXML struct {
+	Loc        string         `xml:"loc"`
+	LastMod    string         `xml:"lastmod,omitempty"`
+	ChangeFreq string         `xml:"changefreq,omitempty"`
+	Priority   float32        `xml:"priority,omitempty"`
+	Images     []imageXML     `xml:"image:image"`
+	Videos     []videoXML     `xml:"video:video"`
+	News       *newsXML       `xml:"news:news"`
+	Alternates []alternateXML `xml:"xhtml:link"`
+}
+
+type imageXML struct {
+	Loc     string `xml:"image:loc"`
+	Title   string `xml:"image:title,omitempty"`
+	Caption string `xml:"image:caption,omitempty"`
+	GeoLoc  string `xml:"image:geo_location,omitempty"`
+	License string `xml:"image:license,omitempty"`
+}
+
+type videoXML struct {
+	ThumbnailLoc         string       `xml:"video:thumbnail_loc"`
+	Title                string       `xml:"video:title"`
+	Description          string       `xml:"video:description"`
+	ContentLoc           string       `xml:"video:content_loc,omitempty"`
+	PlayerLoc            string       `xml:"video:player_loc,omitempty"`
+	Duration             int          `xml:"video:duration,omitempty"`
+	ExpirationDate       string       `xml:"video:expiration_date,omitempty"`
+	Rating               float32      `xml:"video:rating,omitempty"`
+	ViewCount            int          `xml:"video:view_count,omitempty"`
+	PublicationDate      string       `xml:"video:publication_date,omitempty"`
+	FamilyFriendly       string       `xml:"video:family_friendly,omitempty"`
+	Restriction          *Restriction `xml:"video:restriction"`
+	Platform             *Restriction `xml:"video:platform"`
+	RequiresSubscription string       `xml:"video:requires_subscription,omitempty"`
+	Uploader             *Uploader    `xml:"video:uploader"`
+	Live                 string       `xml:"video:live,omitempty"`
+	Tag                  []string     `xml:"video:tag,omitempty"`
+}
+
+type newsXML struct {
+	Publication struct {
+		Name     string `xml:"news:name"`
+		Language string `xml:"news:language"`
+	} `xml:"news:publication"`
+	PublicationDate string `xml:"news:publication_date"`
+	Title           string `xml:"news:title"`
+	Keywords        string `xml:"news:keywords,omitempty"`
+	StockTickers    string `xml:"news:stock_tickers,omitempty"`
+}
+
+type alternateXML struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// MarshalXML writes the <urlset> declaring the namespaces of the
+// extensions used by its URLs
+func (smap Sitemap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	xmlns := smap.Xmlns
+	if xmlns == "" {
+		xmlns = Namespace
+	}
+	out := urlsetXML{
+		Xmlns:          xmlns,
+		SchemaLocation: smap.SchemaLocation,
+		URL:            smap.URL,
+	}
+	if smap.SchemaLocation != "" {
+		out.XmlnsXSI = XSINamespace
+	}
+
+	for _, u := range smap.URL {
+		if len(u.Images) > 0 {
+			out.XmlnsImage = ImageNamespace
+		}
+		if len(u.Videos) > 0 {
+			out.XmlnsVideo = VideoNamespace
+		}
+		if u.News != nil {
+			out.XmlnsNews = NewsNamespace
+		}
+		if len(u.Alternates) > 0 {
+			out.XmlnsXHTML = XHTMLNamespace
+		}
+	}
+
+	return e.Encode(out)
+}
+
+// MarshalXML writes the <url> with prefixed extension elements
+func (u URL) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	out := urlXML{
+		Loc:        u.Loc,
+		LastMod:    u.LastMod,
+		ChangeFreq: u.ChangeFreq,
+		Priority:   u.Priority,
+	}
+
+	for _, img := range u.Images {
+		out.Images = append(out.Images, imageXML(img))
+	}
+	for _, v := range u.Videos {
+		out.Videos = append(out.Videos, videoXML(v))
+	}
+	if u.News != nil {
+		n := &newsXML{
+			PublicationDate: u.News.PublicationDate,
+			Title:           u.News.Title,
+			Keywords:        u.News.Keywords,
+			StockTickers:    u.News.StockTickers,
+		}
+		n.Publication.Name = u.News.Publication.Name
+		n.Publication.Language = u.News.Publication.Language
+		out.News = n
+	}
+	for _, a := range u.Alternates {
+		out.Alternates = append(out.Alternates, alternateXML(a))
+	}
+
+	start.Name = xml.Name{Local: "url"}
+	return e.EncodeElement(out, start)
+}
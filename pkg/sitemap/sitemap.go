@@ -6,55 +6,70 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
 // Index is a structure of <sitemapindex>
 type Index struct {
 	XMLName xml.Name `xml:"sitemapindex"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
 	Sitemap []parts  `xml:"sitemap"`
 }
 
 // parts is a structure of <sitemap> in <sitemapindex>
 type parts struct {
 	Loc     string `xml:"loc"`
-	LastMod string `xml:"lastmod"`
+	LastMod string `xml:"lastmod,omitempty"`
 }
 
 // Sitemap is a structure of <sitemap>
 type Sitemap struct {
-	Xsi            string   `xml:"xsi,attr"`
-	Image          string   `xml:"image,attr"`
-	SchemaLocation string   `xml:"schemaLocation,attr"`
-	Xmlns          string   `xml:"xmlns,attr"`
+	SchemaLocation string   `xml:"http://www.w3.org/2001/XMLSchema-instance schemaLocation,attr,omitempty"`
+	Xmlns          string   `xml:"xmlns,attr,omitempty"`
 	XMLName        xml.Name `xml:"urlset"`
 	URL            []URL    `xml:"url"`
 }
 
 // URL is a structure of <url> in <sitemap>
 type URL struct {
-	Loc        string  `xml:"loc"`
-	LastMod    string  `xml:"lastmod,omitempty"`
-	ChangeFreq string  `xml:"changefreq,omitempty"`
-	Priority   float32 `xml:"priority,omitempty"`
-	// Image      []Image `xml:"image,omitempty"`
+	Loc        string      `xml:"loc"`
+	LastMod    string      `xml:"lastmod,omitempty"`
+	ChangeFreq string      `xml:"changefreq,omitempty"`
+	Priority   float32     `xml:"priority,omitempty"`
+	Images     []Image     `xml:"http://www.google.com/schemas/sitemap-image/1.1 image"`
+	Videos     []Video     `xml:"http://www.google.com/schemas/sitemap-video/1.1 video"`
+	News       *News       `xml:"http://www.google.com/schemas/sitemap-news/0.9 news"`
+	Alternates []Alternate `xml:"http://www.w3.org/1999/xhtml link"`
 }
 
-// Image is a structure of <image> in <url>
-type Image struct {
-	Loc     string `xml:"loc,omitempty"`
-	Title   string `xml:"title,omitempty"`
-	Caption string `xml:"caption,omitempty"`
-	GeoLoc  string `xml:"geolocation,omitempty"`
-	License string `xml:"license,omitempty"`
-}
+// Namespace is the XML namespace of sitemaps and sitemap indexes
+const Namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// Limits of a single sitemap file set by the sitemaps.org protocol
+const (
+	MaxURLs  = 50000
+	MaxBytes = 50 * 1024 * 1024
+)
 
 var (
-	// fetch is page acquisition function
+	// fetch is page acquisition function, options may be a
+	// map[string]string of headers sent with the request
 	fetch = func(URL string, options interface{}) ([]byte, error) {
 		var body []byte
 
-		res, err := http.Get(URL)
+		req, err := http.NewRequest(http.MethodGet, URL, nil)
+		if err != nil {
+			return body, err
+		}
+		if headers, ok := options.(map[string]string); ok {
+			for name, value := range headers {
+				req.Header.Set(name, value)
+			}
+		}
+
+		res, err := http.DefaultClient.Do(req)
 		if err != nil {
 			return body, err
 		}
@@ -62,11 +77,11 @@ var (
 
 		return io.ReadAll(res.Body)
 	}
-
-	// Time interval to be used in Index.get
-	interval = time.Second
 )
 
+// DefaultConcurrency is the maximum number of sitemaps of an index Get and ForceGet fetch at once
+const DefaultConcurrency = 4
+
 /*
 Get is fetch and parse sitemap.xml/sitemapindex.xml
 
@@ -82,6 +97,11 @@ If sitemap.xml or sitemapindex.xml has some problems, This function return error
 If you want to ignore these errors, use the ForceGet function.
 */
 func Get(URL string, options interface{}) (Sitemap, error) {
+	return GetConcurrent(URL, options, DefaultConcurrency)
+}
+
+// GetConcurrent is the Get function fetching at most concurrency sitemaps of an index at once
+func GetConcurrent(URL string, options interface{}, concurrency int) (Sitemap, error) {
 	data, err := fetch(URL, options)
 	if err != nil {
 		return Sitemap{}, err
@@ -101,7 +121,7 @@ func Get(URL string, options interface{}) (Sitemap, error) {
 		return smap, nil
 	}
 
-	smap, err = idx.get(options, false)
+	smap, err = idx.get(options, false, 0, concurrency)
 	if err != nil {
 		return Sitemap{}, err
 	}
@@ -147,7 +167,7 @@ func ForceGet(URL string, options interface{}) (Sitemap, error) {
 		return smap, nil
 	}
 
-	smap, err = idx.get(options, true)
+	smap, err = idx.get(options, true, 0, DefaultConcurrency)
 	if err != nil {
 		return Sitemap{}, err
 	}
@@ -155,26 +175,165 @@ func ForceGet(URL string, options interface{}) (Sitemap, error) {
 	return smap, nil
 }
 
-// Get Sitemap data from sitemapindex file
-func (idx *Index) get(options interface{}, ignoreErr bool) (Sitemap, error) {
-	var smap Sitemap
+// Get Sitemap data from sitemapindex file, merging the URLs of every
+// sitemap it lists in order and dropping the duplicated ones
+func (idx *Index) get(options interface{}, ignoreErr bool, depth int, concurrency int) (Sitemap, error) {
+	smaps, errs := idx.children(options, ignoreErr, depth, concurrency)
+
+	smap := New(nil)
+	seen := map[string]bool{}
+	for i, child := range smaps {
+		if errs[i] != nil {
+			if !ignoreErr {
+				return Sitemap{}, errs[i]
+			}
+			continue
+		}
 
-	for _, s := range idx.Sitemap {
-		time.Sleep(interval)
-		data, err := fetch(s.Loc, options)
-		if !ignoreErr && err != nil {
-			return smap, fmt.Errorf("failed to retrieve %s in sitemapindex.xml: %v", s.Loc, err)
+		if smap.SchemaLocation == "" {
+			smap.SchemaLocation = child.SchemaLocation
 		}
+		for _, u := range child.URL {
+			if seen[u.Loc] {
+				continue
+			}
+			seen[u.Loc] = true
+			smap.URL = append(smap.URL, u)
+		}
+	}
+
+	return smap, nil
+}
+
+// children fetches every sitemap of the index, at most concurrency at once,
+// and returns them along with their errors in the order of the index
+func (idx *Index) children(options interface{}, ignoreErr bool, depth int, concurrency int) ([]Sitemap, []error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
 
-		err = xml.Unmarshal(data, &smap)
-		if !ignoreErr && err != nil {
-			return smap, fmt.Errorf("failed to parse %s in sitemapindex.xml: %v", s.Loc, err)
+	smaps := make([]Sitemap, len(idx.Sitemap))
+	errs := make([]error, len(idx.Sitemap))
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, s := range idx.Sitemap {
+		wg.Add(1)
+		go func(i int, loc string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			smaps[i], errs[i] = child(loc, options, ignoreErr, depth, concurrency)
+		}(i, s.Loc)
+	}
+	wg.Wait()
+
+	return smaps, errs
+}
+
+// child fetches a sitemap listed by an index. Indexes listing other
+// indexes are followed up to maxIndexDepth
+func child(loc string, options interface{}, ignoreErr bool, depth int, concurrency int) (Sitemap, error) {
+	data, err := fetch(loc, options)
+	if err != nil {
+		return Sitemap{}, fmt.Errorf("failed to retrieve %s in sitemapindex.xml: %v", loc, err)
+	}
+
+	if idx, err := ParseIndex(data); err == nil {
+		if depth+1 >= maxIndexDepth {
+			return Sitemap{}, fmt.Errorf("sitemapindex.xml %s is nested too deep", loc)
 		}
+		return idx.get(options, ignoreErr, depth+1, concurrency)
+	}
+
+	smap, err := Parse(data)
+	if err != nil {
+		return Sitemap{}, fmt.Errorf("failed to parse %s in sitemapindex.xml: %v", loc, err)
 	}
 
 	return smap, nil
 }
 
+/*
+GetIndex is fetch and parse sitemapindex.xml along with every sitemap.xml it lists,
+keeping them apart so the structure of the site can be mirrored.
+The sitemaps are returned in the order of the index.
+
+At most concurrency sitemaps are fetched at once.
+When URL is a sitemap.xml, the index is empty and the sitemap is the only one returned.
+It returns the same errors as the Get function.
+*/
+func GetIndex(URL string, options interface{}, concurrency int) (Index, []Sitemap, error) {
+	data, err := fetch(URL, options)
+	if err != nil {
+		return Index{}, nil, err
+	}
+
+	idx, idxErr := ParseIndex(data)
+	if idxErr != nil {
+		smap, err := Parse(data)
+		if err != nil {
+			return Index{}, nil, fmt.Errorf("URL is not a sitemap or sitemapindex: %v", err)
+		}
+		return Index{}, []Sitemap{smap}, nil
+	}
+
+	smaps, errs := idx.children(options, false, 0, concurrency)
+	for _, err := range errs {
+		if err != nil {
+			return Index{}, nil, err
+		}
+	}
+
+	return idx, smaps, nil
+}
+
+// maxIndexDepth limits how deep sitemap indexes that list other indexes are followed
+const maxIndexDepth = 3
+
+// Locs fetches sitemap.xml/sitemapindex.xml and returns the <loc> of every page,
+// reading every sitemap of an index separately. When a child sitemap fails
+// the locs of the other ones are returned along with the first error
+func Locs(URL string, options interface{}) ([]string, error) {
+	return locs(URL, options, 0)
+}
+
+func locs(URL string, options interface{}, depth int) ([]string, error) {
+	data, err := fetch(URL, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if idx, err := ParseIndex(data); err == nil {
+		if depth >= maxIndexDepth {
+			return nil, fmt.Errorf("sitemapindex.xml %s is nested too deep", URL)
+		}
+
+		var list []string
+		var firstErr error
+		for _, s := range idx.Sitemap {
+			child, err := locs(s.Loc, options, depth+1)
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to retrieve %s in sitemapindex.xml: %v", s.Loc, err)
+			}
+			list = append(list, child...)
+		}
+		return list, firstErr
+	}
+
+	smap, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("URL is not a sitemap or sitemapindex: %v", err)
+	}
+
+	var list []string
+	for _, u := range smap.URL {
+		list = append(list, u.Loc)
+	}
+	return list, nil
+}
+
 // Parse create Sitemap data from text
 func Parse(data []byte) (Sitemap, error) {
 	var smap Sitemap
@@ -197,10 +356,12 @@ func ParseIndex(data []byte) (Index, error) {
 	return idx, err
 }
 
-// SetInterval change Time interval to be used in Index.get
-func SetInterval(time time.Duration) {
-	interval = time
-}
+// SetInterval used to change the time interval between the sitemaps fetched by Index.get.
+// It does nothing.
+//
+// Deprecated: the sitemaps of an index are fetched concurrently instead of one
+// every interval, limit them with GetConcurrent and GetIndex
+func SetInterval(time.Duration) {}
 
 // SetFetch change fetch closure
 func SetFetch(f func(URL string, options interface{}) ([]byte, error)) {
@@ -239,3 +400,99 @@ func (smap *Sitemap) Save(dir, file string) error {
 
 	return nil
 }
+
+// New creates a Sitemap with the URLs
+func New(urls []URL) Sitemap {
+	return Sitemap{Xmlns: Namespace, URL: urls}
+}
+
+// Split divides the URLs into sitemaps of at most maxURLs entries
+// and maxBytes bytes each, once marshaled
+func Split(urls []URL, maxURLs int, maxBytes int64) ([]Sitemap, error) {
+	// Room for the XML header and the <urlset> element, declaring every
+	// extension namespace in case the URLs use them
+	overhead, err := xml.MarshalIndent(urlsetXML{
+		Xmlns:      Namespace,
+		XmlnsImage: ImageNamespace,
+		XmlnsVideo: VideoNamespace,
+		XmlnsNews:  NewsNamespace,
+		XmlnsXHTML: XHTMLNamespace,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	base := int64(len(xml.Header) + len(overhead))
+
+	var smaps []Sitemap
+	var current []URL
+	size := base
+	for _, u := range urls {
+		data, err := xml.MarshalIndent(u, "  ", "  ")
+		if err != nil {
+			return nil, err
+		}
+		entry := int64(len(data)) + 1
+
+		if base+entry > maxBytes {
+			return nil, fmt.Errorf("URL %s does not fit in a sitemap of %d bytes", u.Loc, maxBytes)
+		}
+
+		if len(current) > 0 && (len(current) >= maxURLs || size+entry > maxBytes) {
+			smaps = append(smaps, New(current))
+			current = nil
+			size = base
+		}
+
+		current = append(current, u)
+		size += entry
+	}
+
+	if len(current) > 0 || len(smaps) == 0 {
+		smaps = append(smaps, New(current))
+	}
+
+	return smaps, nil
+}
+
+// LastMod returns the most recent lastmod of the sitemap, comparing the
+// W3C datetime strings, which sort chronologically when they share a time zone
+func (smap *Sitemap) LastMod() string {
+	var lastMod string
+	for _, u := range smap.URL {
+		if u.LastMod > lastMod {
+			lastMod = u.LastMod
+		}
+	}
+	return lastMod
+}
+
+// NewIndex creates an empty sitemap index
+func NewIndex() Index {
+	return Index{Xmlns: Namespace}
+}
+
+// Add lists a sitemap in the index
+func (idx *Index) Add(loc string, lastMod string) {
+	idx.Sitemap = append(idx.Sitemap, parts{Loc: loc, LastMod: lastMod})
+}
+
+// Print shows the sitemap index from Index struct
+func (idx *Index) Print() ([]byte, error) {
+	return xml.MarshalIndent(idx, "", "  ")
+}
+
+// Save creates the sitemap index from Index struct and save it to file
+func (idx *Index) Save(dir, file string) error {
+	data, err := idx.Print()
+	if err != nil {
+		return err
+	}
+
+	data = append([]byte(xml.Header), data...)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, file), data, 0644)
+}
@@ -0,0 +1,135 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSplit(t *testing.T) {
+	urls := func(n int) []URL {
+		var list []URL
+		for i := 0; i < n; i++ {
+			list = append(list, URL{Loc: fmt.Sprintf("https://example.com/page-%03d/", i), LastMod: "2024-01-01"})
+		}
+		return list
+	}
+
+	tests := []struct {
+		name     string
+		urls     []URL
+		maxURLs  int
+		maxBytes int64
+		want     []int
+		wantErr  bool
+	}{
+		{name: "no URLs", urls: nil, maxURLs: 10, maxBytes: 1 << 20, want: []int{0}},
+		{name: "fits in one", urls: urls(5), maxURLs: 10, maxBytes: 1 << 20, want: []int{5}},
+		{name: "exactly max URLs", urls: urls(10), maxURLs: 10, maxBytes: 1 << 20, want: []int{10}},
+		{name: "split by count", urls: urls(25), maxURLs: 10, maxBytes: 1 << 20, want: []int{10, 10, 5}},
+		{name: "split by size", urls: urls(10), maxURLs: 100, maxBytes: 1024, want: []int{7, 3}},
+		{name: "URL larger than a sitemap", urls: urls(1), maxURLs: 10, maxBytes: 300, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			smaps, err := Split(tt.urls, tt.maxURLs, tt.maxBytes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Split() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var counts []int
+			var locs []string
+			for _, smap := range smaps {
+				counts = append(counts, len(smap.URL))
+				for _, u := range smap.URL {
+					locs = append(locs, u.Loc)
+				}
+
+				data, err := smap.Print()
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Save adds the XML header
+				if size := int64(len(xml.Header) + len(data)); size > tt.maxBytes {
+					t.Errorf("sitemap of %d bytes is over the limit of %d", size, tt.maxBytes)
+				}
+			}
+			if !reflect.DeepEqual(counts, tt.want) {
+				t.Errorf("Split() sizes = %v, want %v", counts, tt.want)
+			}
+
+			// Every URL is kept, in order
+			var want []string
+			for _, u := range tt.urls {
+				want = append(want, u.Loc)
+			}
+			if strings.Join(locs, " ") != strings.Join(want, " ") {
+				t.Errorf("Split() URLs = %v, want %v", locs, want)
+			}
+		})
+	}
+}
+
+func TestGetConcurrent(t *testing.T) {
+	const children = 8
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+
+	defer SetFetch(fetch)
+	SetFetch(func(URL string, options interface{}) ([]byte, error) {
+		if URL == "https://example.com/sitemap_index.xml" {
+			var b strings.Builder
+			b.WriteString(`<sitemapindex xmlns="` + Namespace + `">`)
+			for i := 0; i < children; i++ {
+				fmt.Fprintf(&b, "<sitemap><loc>https://example.com/sitemap-%d.xml</loc></sitemap>", i)
+			}
+			b.WriteString(`</sitemapindex>`)
+			return []byte(b.String()), nil
+		}
+
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		loc := strings.Replace(strings.TrimSuffix(URL, ".xml"), "sitemap-", "page-", 1)
+		return []byte(`<urlset xmlns="` + Namespace + `"><url><loc>` + loc + `/</loc></url></urlset>`), nil
+	})
+
+	for _, concurrency := range []int{1, 3} {
+		t.Run(fmt.Sprint(concurrency), func(t *testing.T) {
+			peak = 0
+
+			smap, err := GetConcurrent("https://example.com/sitemap_index.xml", nil, concurrency)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(smap.URL) != children {
+				t.Errorf("got %d URLs, want %d", len(smap.URL), children)
+			}
+			if smap.URL[0].Loc != "https://example.com/page-0/" {
+				t.Errorf("first URL = %s, want the one of the first sitemap", smap.URL[0].Loc)
+			}
+			if peak > concurrency {
+				t.Errorf("fetched %d sitemaps at once, want at most %d", peak, concurrency)
+			}
+		})
+	}
+}
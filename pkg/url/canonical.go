@@ -0,0 +1,125 @@
+package url
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Query policies define how URLs with a query string are mapped to files
+const (
+	// QueryPolicyEncode saves the URL to a file whose name includes the query string, see QueryFileName
+	QueryPolicyEncode = "encode"
+	// QueryPolicyIgnore saves the URL to the file of its path, the query string is dropped
+	QueryPolicyIgnore = "ignore"
+	// QueryPolicySkip does not download URLs that still have a query string after canonicalisation
+	QueryPolicySkip = "skip"
+)
+
+// ValidateQueryPolicy checks that the query policy is known
+func ValidateQueryPolicy(policy string) error {
+	switch policy {
+	case QueryPolicyEncode, QueryPolicyIgnore, QueryPolicySkip:
+		return nil
+	}
+	return fmt.Errorf("unknown query policy: %s", policy)
+}
+
+// Canonicalizer normalises URLs so that equivalent ones are downloaded once
+type Canonicalizer struct {
+	strip     []string
+	sortQuery bool
+}
+
+// NewCanonicalizer creates a Canonicalizer that removes the named query parameters,
+// a trailing * matches every parameter with that prefix, like utm_*
+func NewCanonicalizer(strip []string, sortQuery bool) *Canonicalizer {
+	var params []string
+	for _, param := range strip {
+		params = append(params, strings.ToLower(strings.TrimSpace(param)))
+	}
+
+	return &Canonicalizer{
+		strip:     params,
+		sortQuery: sortQuery,
+	}
+}
+
+// Canonical returns a copy of the URL with a lowercase scheme and host, without
+// default port, with at least the root path, without the stripped parameters
+// and, when enabled, with sorted parameters
+func (c *Canonicalizer) Canonical(u *url.URL) *url.URL {
+	canonical := *u
+	canonical.Scheme = strings.ToLower(u.Scheme)
+	canonical.Host = CanonicalHost(u)
+	if canonical.Host != "" && canonical.Path == "" {
+		canonical.Path = "/"
+	}
+
+	if u.RawQuery == "" {
+		canonical.ForceQuery = false
+		return &canonical
+	}
+
+	var params []string
+	for _, param := range strings.Split(u.RawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err == nil && c.isStripped(name) {
+			continue
+		}
+		params = append(params, param)
+	}
+
+	if c.sortQuery {
+		// Stable, so repeated keys keep their order
+		sort.SliceStable(params, func(i, j int) bool {
+			ki, _, _ := strings.Cut(params[i], "=")
+			kj, _, _ := strings.Cut(params[j], "=")
+			return ki < kj
+		})
+	}
+
+	canonical.RawQuery = strings.Join(params, "&")
+	canonical.ForceQuery = false
+
+	return &canonical
+}
+
+// isStripped reports whether the parameter must be removed
+func (c *Canonicalizer) isStripped(name string) bool {
+	name = strings.ToLower(name)
+	for _, param := range c.strip {
+		if prefix, ok := strings.CutSuffix(param, "*"); ok && strings.HasPrefix(name, prefix) {
+			return true
+		}
+		if param == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CanonicalHost returns the lowercase host of the URL without the default port of its scheme
+func CanonicalHost(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return host
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		if strings.Contains(hostname, ":") {
+			return "[" + hostname + "]"
+		}
+		return hostname
+	}
+
+	return host
+}
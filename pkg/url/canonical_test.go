@@ -0,0 +1,103 @@
+package url
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name      string
+		strip     []string
+		sortQuery bool
+		url       string
+		want      string
+	}{
+		{
+			name: "lowercase scheme and host",
+			url:  "HTTPS://Example.COM/About",
+			want: "https://example.com/About",
+		},
+		{
+			name: "default port",
+			url:  "http://example.com:80/a",
+			want: "http://example.com/a",
+		},
+		{
+			name: "other port",
+			url:  "http://example.com:8080/a",
+			want: "http://example.com:8080/a",
+		},
+		{
+			name: "root path",
+			url:  "https://example.com",
+			want: "https://example.com/",
+		},
+		{
+			name: "empty query",
+			url:  "https://example.com/a?",
+			want: "https://example.com/a",
+		},
+		{
+			name:  "stripped parameters",
+			strip: []string{"utm_*", "fbclid"},
+			url:   "https://example.com/a?utm_source=x&p=1&FBCLID=y&utm_medium=z",
+			want:  "https://example.com/a?p=1",
+		},
+		{
+			name:  "every parameter stripped",
+			strip: []string{"utm_*"},
+			url:   "https://example.com/a?utm_source=x",
+			want:  "https://example.com/a",
+		},
+		{
+			name:      "sorted parameters",
+			sortQuery: true,
+			url:       "https://example.com/a?b=2&a=1&b=1",
+			want:      "https://example.com/a?a=1&b=2&b=1",
+		},
+		{
+			name: "order kept",
+			url:  "https://example.com/a?b=2&a=1",
+			want: "https://example.com/a?b=2&a=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := NewCanonicalizer(tt.strip, tt.sortQuery)
+			if got := c.Canonical(u).String(); got != tt.want {
+				t.Errorf("Canonical(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalHost(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://Example.com", want: "example.com"},
+		{url: "https://example.com:443", want: "example.com"},
+		{url: "http://example.com:443", want: "example.com:443"},
+		{url: "http://[::1]:80", want: "[::1]"},
+		{url: "http://[::1]:8080", want: "[::1]:8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := CanonicalHost(u); got != tt.want {
+				t.Errorf("CanonicalHost(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package url
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Rewriter rewrites references to the original site so they point to the replacement URL,
+// or to the file they are saved to when relative links are enabled
+type Rewriter struct {
+	host     string
+	replace  string
+	relative bool
+	// query canonicalises the query strings encoded into file names, nil keeps them in the URL
+	query *Canonicalizer
+}
+
+// NewRewriter creates a Rewriter for the site URL.
+// An empty replace URL turns same-site references into root relative ones.
+// When query is set, same-site references with a query string point to the
+// file the query is encoded into, as saved with the encode query policy
+func NewRewriter(siteURL string, replaceURL string, relative bool, query *Canonicalizer) (*Rewriter, error) {
+	u, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing URL: %v", err)
+	}
+
+	return &Rewriter{
+		host:     CanonicalHost(u),
+		replace:  strings.TrimSuffix(replaceURL, "/"),
+		relative: relative,
+		query:    query,
+	}, nil
+}
+
+// IsSameSite reports whether the reference is an absolute or protocol-relative URL of the site
+func (r *Rewriter) IsSameSite(ref string) bool {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return false
+	}
+
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+
+	return u.Host != "" && CanonicalHost(u) == r.host
+}
+
+// Rewrite returns the reference pointing to the replacement URL.
+// URLs encoded in the query string, like share links, are rewritten as well
+func (r *Rewriter) Rewrite(ref string) string {
+	return r.rewrite(ref, "", nil)
+}
+
+// For returns a rewrite function for the references found in file, the path of
+// the file relative to the output directory, downloaded from page. In relative
+// mode every same-site reference becomes a path relative to that file.
+// Document relative references with a query string are resolved against page,
+// so they point to the file the query is encoded into; page may be nil
+func (r *Rewriter) For(file string, page *url.URL) func(string) string {
+	file = filepath.ToSlash(file)
+	return func(ref string) string {
+		return r.rewrite(ref, file, page)
+	}
+}
+
+func (r *Rewriter) rewrite(ref string, file string, page *url.URL) string {
+	trimmed := strings.TrimSpace(ref)
+	u, err := url.Parse(trimmed)
+	if err != nil {
+		return ref
+	}
+
+	if r.query != nil && page != nil && file != "" && isDocumentRelative(u) && u.RawQuery != "" {
+		return r.rewriteDocumentRelative(u, file, page)
+	}
+
+	rawQuery := r.rewriteQuery(u.RawQuery)
+
+	sameSite := r.IsSameSite(trimmed)
+	rootRelative := u.Scheme == "" && u.Host == "" && strings.HasPrefix(u.Path, "/")
+	relative := r.relative && file != ""
+
+	// The query string of these is part of the file name
+	encoded := r.query != nil && (sameSite || rootRelative) && u.RawQuery != ""
+
+	if !sameSite && !(relative && rootRelative) && !encoded {
+		if rawQuery == u.RawQuery {
+			return ref
+		}
+		u.RawQuery = rawQuery
+		return u.String()
+	}
+
+	target := *u
+	target.RawQuery = ""
+	if encoded {
+		target = *r.query.Canonical(u)
+		rawQuery = ""
+	}
+
+	var rewritten string
+	switch {
+	case relative:
+		rewritten = relativePath(file, FilePath(&target))
+	case target.RawQuery != "":
+		rewritten = r.replace + (&url.URL{Path: "/" + FilePath(&target)}).EscapedPath()
+	default:
+		rewritten = r.replace + u.EscapedPath()
+		if rewritten == "" {
+			rewritten = "/"
+		}
+	}
+
+	if rawQuery != "" {
+		rewritten += "?" + rawQuery
+	}
+	if u.Fragment != "" {
+		rewritten += "#" + u.EscapedFragment()
+	}
+
+	return rewritten
+}
+
+// rewriteDocumentRelative returns the path of the file the reference is saved to,
+// relative to file, the reference resolved against page having its query string
+// encoded into the file name. It stays relative, whatever the mode
+func (r *Rewriter) rewriteDocumentRelative(u *url.URL, file string, page *url.URL) string {
+	target := r.query.Canonical(page.ResolveReference(u))
+
+	rewritten := relativePath(file, FilePath(target))
+	if u.Fragment != "" {
+		rewritten += "#" + u.EscapedFragment()
+	}
+	return rewritten
+}
+
+// isDocumentRelative reports whether the reference is relative to the path of the page,
+// like ../fonts/font.woff2 or ?paged=2
+func isDocumentRelative(u *url.URL) bool {
+	return u.Scheme == "" && u.Host == "" && u.Opaque == "" && !strings.HasPrefix(u.Path, "/")
+}
+
+// relativePath returns the escaped path of target relative to the directory of file
+func relativePath(file string, target string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(path.Dir(file)), filepath.FromSlash(target))
+	if err != nil {
+		rel = target
+	}
+
+	// Let url.URL escape the path and protect segments that look like a scheme
+	return (&url.URL{Path: filepath.ToSlash(rel)}).String()
+}
+
+// rewriteQuery rewrites the query values that hold an encoded URL of the site,
+// leaving the order and encoding of every other parameter untouched.
+// These always point to the replacement URL, since they are meant to be shared,
+// and are left alone when there is no replacement URL
+func (r *Rewriter) rewriteQuery(rawQuery string) string {
+	if rawQuery == "" || r.replace == "" {
+		return rawQuery
+	}
+
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key, value, found := strings.Cut(param, "=")
+		if !found {
+			continue
+		}
+
+		decoded, err := url.QueryUnescape(value)
+		if err != nil || !strings.Contains(decoded, "//") {
+			continue
+		}
+
+		rewritten := r.Rewrite(decoded)
+		if rewritten != decoded {
+			params[i] = key + "=" + url.QueryEscape(rewritten)
+		}
+	}
+
+	return strings.Join(params, "&")
+}
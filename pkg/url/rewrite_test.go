@@ -0,0 +1,187 @@
+package url
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace string
+		encode  bool
+		ref     string
+		want    string
+	}{
+		{
+			name:    "same site",
+			replace: "https://static.example.com",
+			ref:     "https://example.com/about/",
+			want:    "https://static.example.com/about/",
+		},
+		{
+			name:    "protocol relative",
+			replace: "https://static.example.com",
+			ref:     "//example.com/about/",
+			want:    "https://static.example.com/about/",
+		},
+		{
+			name:    "uppercase host and default port",
+			replace: "https://static.example.com",
+			ref:     "https://EXAMPLE.com:443/a#top",
+			want:    "https://static.example.com/a#top",
+		},
+		{
+			name:    "other site",
+			replace: "https://static.example.com",
+			ref:     "https://other.com/about/",
+			want:    "https://other.com/about/",
+		},
+		{
+			name:    "root relative",
+			replace: "https://static.example.com",
+			ref:     "/about/",
+			want:    "/about/",
+		},
+		{
+			name:    "share link",
+			replace: "https://static.example.com",
+			ref:     "https://twitter.com/share?text=Hi&url=https%3A%2F%2Fexample.com%2Fpost%2F",
+			want:    "https://twitter.com/share?text=Hi&url=https%3A%2F%2Fstatic.example.com%2Fpost%2F",
+		},
+		{
+			name: "no replacement URL",
+			ref:  "https://example.com/about/?x=1",
+			want: "/about/?x=1",
+		},
+		{
+			name:   "encoded query",
+			encode: true,
+			ref:    "https://example.com/?p=5",
+			want:   "/index-p-5.html",
+		},
+		{
+			name:   "encoded query of a root relative reference",
+			encode: true,
+			ref:    "/blog/?paged=2",
+			want:   "/blog/index-paged-2.html",
+		},
+		{
+			name:    "encoded query with a replacement URL",
+			replace: "https://static.example.com",
+			encode:  true,
+			ref:     "https://example.com/fonts/fa.woff2?v=4.7.0",
+			want:    "https://static.example.com/fonts/fa-v-4.7.0.woff2",
+		},
+		{
+			name: "not a URL",
+			ref:  "mailto:me@example.com",
+			want: "mailto:me@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRewriter(t, tt.replace, false, tt.encode)
+			if got := r.Rewrite(tt.ref); got != tt.want {
+				t.Errorf("Rewrite(%q) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		relative bool
+		encode   bool
+		file     string
+		page     string
+		ref      string
+		want     string
+	}{
+		{
+			name:     "relative page",
+			relative: true,
+			file:     "blog/index.html",
+			page:     "https://example.com/blog/",
+			ref:      "https://example.com/about/",
+			want:     "../about/index.html",
+		},
+		{
+			name:     "relative root relative reference",
+			relative: true,
+			file:     "blog/index.html",
+			page:     "https://example.com/blog/",
+			ref:      "/css/style.css#x",
+			want:     "../css/style.css#x",
+		},
+		{
+			name:     "relative encoded query",
+			relative: true,
+			encode:   true,
+			file:     "index.html",
+			page:     "https://example.com/",
+			ref:      "https://example.com/?p=5",
+			want:     "index-p-5.html",
+		},
+		{
+			name:   "document relative asset with a query",
+			encode: true,
+			file:   "css/style.css",
+			page:   "https://example.com/css/style.css",
+			ref:    "../fonts/fa.woff2?v=4.7.0#iefix",
+			want:   "../fonts/fa-v-4.7.0.woff2#iefix",
+		},
+		{
+			name:   "document relative query only",
+			encode: true,
+			file:   "blog/index.html",
+			page:   "https://example.com/blog/",
+			ref:    "?paged=2",
+			want:   "index-paged-2.html",
+		},
+		{
+			name:   "document relative without a query",
+			encode: true,
+			file:   "css/style.css",
+			page:   "https://example.com/css/style.css",
+			ref:    "../img/bg.png",
+			want:   "../img/bg.png",
+		},
+		{
+			name: "document relative query kept without encoding",
+			file: "blog/index.html",
+			page: "https://example.com/blog/",
+			ref:  "?paged=2",
+			want: "?paged=2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRewriter(t, "", tt.relative, tt.encode)
+			page, err := url.Parse(tt.page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.For(tt.file, page)(tt.ref); got != tt.want {
+				t.Errorf("For(%q, %q)(%q) = %q, want %q", tt.file, tt.page, tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func newTestRewriter(t *testing.T, replace string, relative bool, encode bool) *Rewriter {
+	t.Helper()
+
+	var query *Canonicalizer
+	if encode {
+		query = NewCanonicalizer(nil, false)
+	}
+	r, err := NewRewriter("https://example.com", replace, relative, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
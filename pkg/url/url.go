@@ -1,19 +1,114 @@
 package url
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"net/url"
+	"path"
 	"path/filepath"
+	"strings"
 )
 
-// ParsePath parses the URL and returns the base directory and file name
+// maxQueryName is the longest encoded query string kept in a file name, longer ones are hashed
+const maxQueryName = 100
+
+// ParsePath parses the URL and returns the base directory and file name.
+// Paths without extension are directories, so the file name is empty
 func ParsePath(urlString string) (string, string, error) {
 	u, err := url.Parse(urlString)
 	if err != nil {
 		return "", "", fmt.Errorf("error parsing URL: %v", err)
 	}
 	path := u.Path
+	if path == "" || strings.HasSuffix(path, "/") || filepath.Ext(path) == "" {
+		return filepath.Clean("/" + path), "", nil
+	}
 	baseDir := filepath.Dir(path)
 	fileName := filepath.Base(path)
 	return baseDir, fileName, nil
 }
+
+// FilePath returns the path of the file the URL is saved to, relative to the output directory.
+// URLs without extension are expected to be HTML pages. The query string, if any, is encoded
+// into the file name, see QueryFileName
+func FilePath(u *url.URL) string {
+	p := strings.TrimPrefix(u.Path, "/")
+	if p == "" || strings.HasSuffix(p, "/") || path.Ext(p) == "" {
+		p = path.Join(p, "index.html")
+	}
+	return path.Join(path.Dir(p), QueryFileName(path.Base(p), u.RawQuery))
+}
+
+// QueryFileName encodes the query string into the file name, before its extension,
+// so index.html with p=123 becomes index-p-123.html. Characters that are not safe
+// in a file name are replaced, and a short hash of the query is added when that
+// loses information, so different queries never share a file. Parameters without
+// a value are ambiguous, a&b and a=b would both give a-b, so they are hashed too
+func QueryFileName(fileName string, rawQuery string) string {
+	if rawQuery == "" {
+		return fileName
+	}
+
+	var parts, decoded []string
+	lossy := false
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(param, "=")
+		key, value = unescapeQuery(key), unescapeQuery(value)
+		decoded = append(decoded, key+"="+value)
+		if value == "" {
+			lossy = true
+		}
+		for _, s := range []string{key, value} {
+			if s == "" {
+				continue
+			}
+			encoded, changed := sanitizeName(s)
+			lossy = lossy || changed
+			parts = append(parts, encoded)
+		}
+	}
+
+	encoded := strings.Join(parts, "-")
+	if lossy || len(encoded) > maxQueryName {
+		if len(encoded) > maxQueryName {
+			encoded = encoded[:maxQueryName]
+		}
+		// Hash the decoded query, so differently escaped URLs share the file
+		sum := sha1.Sum([]byte(strings.Join(decoded, "&")))
+		encoded += "-" + hex.EncodeToString(sum[:4])
+	}
+	if encoded == "" {
+		return fileName
+	}
+
+	ext := path.Ext(fileName)
+	return strings.TrimSuffix(fileName, ext) + "-" + encoded + ext
+}
+
+// unescapeQuery decodes a query key or value, leaving it as it is when it is not valid
+func unescapeQuery(s string) string {
+	if unescaped, err := url.QueryUnescape(s); err == nil {
+		return unescaped
+	}
+	return s
+}
+
+// sanitizeName keeps only letters, digits, _ and . of a query key or value,
+// it reports whether anything else had to be replaced
+func sanitizeName(s string) (string, bool) {
+	changed := false
+	name := []byte(s)
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.':
+		default:
+			name[i] = '_'
+			changed = true
+		}
+	}
+	return string(name), changed
+}
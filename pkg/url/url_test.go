@@ -0,0 +1,137 @@
+package url
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestQueryFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		rawQuery string
+		want     string
+	}{
+		{
+			name:     "no query",
+			fileName: "index.html",
+			rawQuery: "",
+			want:     "index.html",
+		},
+		{
+			name:     "single parameter",
+			fileName: "index.html",
+			rawQuery: "p=123",
+			want:     "index-p-123.html",
+		},
+		{
+			name:     "several parameters",
+			fileName: "index.html",
+			rawQuery: "paged=2&cat=news",
+			want:     "index-paged-2-cat-news.html",
+		},
+		{
+			name:     "asset version",
+			fileName: "fa.woff2",
+			rawQuery: "v=4.7.0",
+			want:     "fa-v-4.7.0.woff2",
+		},
+		{
+			name:     "empty parameters are dropped",
+			fileName: "index.html",
+			rawQuery: "&p=1&",
+			want:     "index-p-1.html",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QueryFileName(tt.fileName, tt.rawQuery); got != tt.want {
+				t.Errorf("QueryFileName(%q, %q) = %q, want %q", tt.fileName, tt.rawQuery, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryFileNameHashed(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		other  string
+		prefix string
+	}{
+		{
+			name:   "valueless parameters",
+			query:  "a&b",
+			other:  "a=b",
+			prefix: "index-a-b-",
+		},
+		{
+			name:   "unsafe characters",
+			query:  "s=a/b",
+			other:  "s=a_b",
+			prefix: "index-s-a_b-",
+		},
+		{
+			name:   "space",
+			query:  "s=a+b",
+			other:  "s=a_b",
+			prefix: "index-s-a_b-",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := QueryFileName("index.html", tt.query)
+			if !strings.HasPrefix(got, tt.prefix) || !strings.HasSuffix(got, ".html") {
+				t.Errorf("QueryFileName(%q) = %q, want %s<hash>.html", tt.query, got, tt.prefix)
+			}
+			if other := QueryFileName("index.html", tt.other); other == got {
+				t.Errorf("QueryFileName(%q) and QueryFileName(%q) are both %q", tt.query, tt.other, got)
+			}
+		})
+	}
+}
+
+func TestQueryFileNameEscaping(t *testing.T) {
+	// Differently escaped queries are the same query
+	a := QueryFileName("index.html", "s=a%2Fb")
+	b := QueryFileName("index.html", "s=a/b")
+	if a != b {
+		t.Errorf("QueryFileName of escaped and unescaped queries differ: %q, %q", a, b)
+	}
+
+	long := QueryFileName("index.html", "q="+strings.Repeat("x", 200))
+	if len(long) > maxQueryName+len("index-.html")+9 {
+		t.Errorf("QueryFileName of a long query is not shortened: %q", long)
+	}
+}
+
+func TestFilePath(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://example.com", want: "index.html"},
+		{url: "https://example.com/", want: "index.html"},
+		{url: "https://example.com/about", want: "about/index.html"},
+		{url: "https://example.com/about/", want: "about/index.html"},
+		{url: "https://example.com/css/style.css", want: "css/style.css"},
+		{url: "https://example.com/?p=5", want: "index-p-5.html"},
+		{url: "https://example.com/blog/?paged=2", want: "blog/index-paged-2.html"},
+		{url: "https://example.com/fonts/fa.woff2?v=4.7.0", want: "fonts/fa-v-4.7.0.woff2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := FilePath(u); got != tt.want {
+				t.Errorf("FilePath(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package wpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// perPage is the largest page size the WordPress REST API accepts
+const perPage = 100
+
+// timeout bounds every request, so an endpoint that hangs does not stall the run
+const timeout = 30 * time.Second
+
+// DefaultTypes are the endpoints of wp/v2 that return public URLs
+var DefaultTypes = []string{"posts", "pages", "categories", "tags", "users"}
+
+// nonPostTypes are the endpoints of wp/v2 that do not filter by post status
+var nonPostTypes = map[string]bool{"categories": true, "tags": true, "users": true, "comments": true, "media": true}
+
+// Client lists the URLs of a WordPress site through its REST API
+type Client struct {
+	base    string
+	headers http.Header
+	client  *http.Client
+}
+
+// New creates a Client for the site URL. user and password are an application
+// password, needed when the REST API is restricted to logged in users, and are not
+// sent when empty. Only published content is listed either way, the crawler
+// fetches the links anonymously
+func New(siteURL string, user string, password string, headers map[string]string) (*Client, error) {
+	u, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing URL: %v", err)
+	}
+
+	c := &Client{
+		base:    strings.TrimSuffix(u.Scheme+"://"+u.Host+u.Path, "/") + "/wp-json/wp/v2/",
+		headers: make(http.Header),
+		client:  &http.Client{Timeout: timeout},
+	}
+
+	for name, value := range headers {
+		c.headers.Set(name, value)
+	}
+
+	if user != "" || password != "" {
+		req := &http.Request{Header: make(http.Header)}
+		req.SetBasicAuth(user, password)
+		c.headers.Set("Authorization", req.Header.Get("Authorization"))
+	}
+
+	return c, nil
+}
+
+// item is the part of a REST API object that is used
+type item struct {
+	Link string `json:"link"`
+}
+
+// Links pages through the endpoint, like posts or a custom post type,
+// and returns the link of every item
+func (c *Client) Links(endpoint string) ([]string, error) {
+	var links []string
+
+	for page := 1; ; page++ {
+		items, totalPages, err := c.page(endpoint, page)
+		if err != nil {
+			return links, err
+		}
+
+		for _, item := range items {
+			if item.Link != "" {
+				links = append(links, item.Link)
+			}
+		}
+
+		if len(items) < perPage || (totalPages > 0 && page >= totalPages) {
+			return links, nil
+		}
+	}
+}
+
+// page fetches a page of the endpoint and returns its items and the total number of pages
+func (c *Client) page(endpoint string, page int) ([]item, int, error) {
+	query := url.Values{}
+	query.Set("per_page", strconv.Itoa(perPage))
+	query.Set("page", strconv.Itoa(page))
+	query.Set("_fields", "link")
+
+	endpoint = strings.Trim(endpoint, "/")
+	if !nonPostTypes[endpoint] {
+		// Authenticated requests could list drafts and private posts, which are not public
+		query.Set("status", "publish")
+	}
+	if endpoint == "users" {
+		// As listed anonymously, users without public content have no archive worth scraping
+		query.Set("has_published_posts", "true")
+	}
+
+	endpointURL := c.base + endpoint + "?" + query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, endpointURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header = c.headers.Clone()
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("error listing %s: status %d", endpoint, res.StatusCode)
+	}
+
+	var items []item
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, 0, fmt.Errorf("error parsing %s: %v", endpoint, err)
+	}
+
+	totalPages, _ := strconv.Atoi(res.Header.Get("X-WP-TotalPages"))
+
+	return items, totalPages, nil
+}
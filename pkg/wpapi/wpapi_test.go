@@ -0,0 +1,51 @@
+package wpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestLinksQuery(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		user     string
+		want     map[string]string
+	}{
+		{endpoint: "posts", want: map[string]string{"status": "publish"}},
+		{endpoint: "posts", user: "admin", want: map[string]string{"status": "publish"}},
+		{endpoint: "portfolio", user: "admin", want: map[string]string{"status": "publish"}},
+		{endpoint: "categories", user: "admin", want: map[string]string{"status": ""}},
+		{endpoint: "users", user: "admin", want: map[string]string{"status": "", "has_published_posts": "true"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint+"/"+tt.user, func(t *testing.T) {
+			got := map[string]string{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key := range tt.want {
+					got[key] = r.URL.Query().Get(key)
+				}
+				json.NewEncoder(w).Encode([]item{{Link: "https://example.com/a/"}})
+			}))
+			defer server.Close()
+
+			c, err := New(server.URL, tt.user, "secret", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			links, err := c.Links(tt.endpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(links) != 1 {
+				t.Errorf("Links() = %v, want one link", links)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("query = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}